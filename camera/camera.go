@@ -10,6 +10,8 @@ import "C"
 import (
 	"sync"
 	"unsafe"
+
+	"github.com/akhenakh/xplane-go/util"
 )
 
 // Position holds all data for a camera's location and orientation.
@@ -73,6 +75,9 @@ func cameraControl_cgo(outCameraPosition *C.XPLMCameraPosition_t, inIsLosingCont
 		return 0 // Callback not found, surrender control.
 	}
 
+	// A panicking callback is removed, and returning 0 surrenders the camera.
+	defer util.RecoverPanic("camera control", func() { unregisterCallback(id) })
+
 	keepControl, newPos := callback(inIsLosingControl != 0)
 
 	if !keepControl {
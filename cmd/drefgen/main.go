@@ -0,0 +1,281 @@
+// Command drefgen generates a Go package of typed dataref handles and command
+// names from X-Plane's Resources/plugins/DataRefs.txt and CommandRefs.txt.
+//
+// Usage:
+//
+//	drefgen -datarefs "X-Plane 12/Resources/plugins/DataRefs.txt" \
+//		-commands "X-Plane 12/Resources/plugins/CommandRefs.txt" \
+//		-pkg datarefs -prefix sim/flightmodel/,sim/cockpit2/ -o datarefs/datarefs.go
+//
+// Every dataref becomes a dref.Spec (scalars) or dref.ArraySpec (arrays and
+// byte data) variable documented with its units, array size and writability,
+// and every command becomes a string constant.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/akhenakh/xplane-go/dreftxt"
+)
+
+func main() {
+	datarefsPath := flag.String("datarefs", "", "path to DataRefs.txt (required)")
+	commandsPath := flag.String("commands", "", "path to CommandRefs.txt (optional)")
+	pkgName := flag.String("pkg", "datarefs", "name of the generated package")
+	output := flag.String("o", "", "output file (default: stdout)")
+	prefixes := flag.String("prefix", "", "comma-separated list of name prefixes to keep (default: all)")
+	trim := flag.String("trim", "sim/", "prefix removed from names before building Go identifiers")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("drefgen: ")
+
+	if *datarefsPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	refs, err := readDataRefs(*datarefsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var cmds []dreftxt.Command
+	if *commandsPath != "" {
+		if cmds, err = readCommands(*commandsPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	g := &generator{
+		pkg:    *pkgName,
+		trim:   *trim,
+		idents: make(map[string]bool),
+	}
+	if *prefixes != "" {
+		g.prefixes = strings.Split(*prefixes, ",")
+	}
+	src, err := g.generate(refs, cmds)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readDataRefs(path string) ([]dreftxt.DataRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return dreftxt.ParseDataRefs(f)
+}
+
+func readCommands(path string) ([]dreftxt.Command, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return dreftxt.ParseCommands(f)
+}
+
+type generator struct {
+	pkg      string
+	trim     string
+	prefixes []string
+	idents   map[string]bool
+	buf      bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) generate(refs []dreftxt.DataRef, cmds []dreftxt.Command) ([]byte, error) {
+	specs := 0
+	for _, ref := range refs {
+		if !g.keep(ref.Name) {
+			continue
+		}
+		goType, ok := specType(ref)
+		if !ok {
+			log.Printf("skipping %s: unsupported type %s", ref.Name, ref.Type)
+			continue
+		}
+		ident := g.ident(ref.Name, "")
+		g.printf("// %s is %q (%s).\n", ident, ref.Name, describe(ref))
+		g.printDoc(ref.Description)
+		if ref.IsArray() {
+			g.printf("var %s = dref.%s{Name: %q, Units: %q, Len: %d, Writable: %t}\n\n",
+				ident, goType, ref.Name, units(ref.Units), ref.Len(), ref.Writable)
+		} else {
+			g.printf("var %s = dref.%s{Name: %q, Units: %q, Writable: %t}\n\n",
+				ident, goType, ref.Name, units(ref.Units), ref.Writable)
+		}
+		specs++
+	}
+
+	var kept []dreftxt.Command
+	for _, cmd := range cmds {
+		if g.keep(cmd.Name) {
+			kept = append(kept, cmd)
+		}
+	}
+	if len(kept) > 0 {
+		g.printf("// Commands.\nconst (\n")
+		for _, cmd := range kept {
+			ident := g.ident(cmd.Name, "Cmd")
+			g.printf("// %s is the %q command.\n", ident, cmd.Name)
+			g.printDoc(cmd.Description)
+			g.printf("%s = %q\n", ident, cmd.Name)
+		}
+		g.printf(")\n")
+	}
+
+	if specs == 0 && len(kept) == 0 {
+		return nil, fmt.Errorf("no datarefs or commands matched")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by drefgen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "// Package %s provides typed handles for X-Plane datarefs and command names.\n", g.pkg)
+	fmt.Fprintf(&out, "package %s\n\n", g.pkg)
+	// A package of commands only does not use dref.
+	if specs > 0 {
+		fmt.Fprintf(&out, "import \"github.com/akhenakh/xplane-go/dref\"\n\n")
+	}
+	out.Write(g.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+func (g *generator) keep(name string) bool {
+	if len(g.prefixes) == 0 {
+		return true
+	}
+	for _, p := range g.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ident turns a slash-separated name into a unique exported Go identifier,
+// e.g. "sim/flightmodel/engine/ENGN_N1_" becomes "FlightmodelEngineENGNN1".
+func (g *generator) ident(name, prefix string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	upperNext := true
+	for _, r := range strings.TrimPrefix(name, g.trim) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upperNext {
+				r = unicode.ToUpper(r)
+				upperNext = false
+			}
+			b.WriteRune(r)
+		default:
+			upperNext = true
+		}
+	}
+	id := b.String()
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "X" + id
+	}
+
+	// Names differing only by punctuation map to the same identifier.
+	unique := id
+	for n := 2; g.idents[unique]; n++ {
+		unique = fmt.Sprintf("%s%d", id, n)
+	}
+	g.idents[unique] = true
+	return unique
+}
+
+// printDoc writes a description as a comment wrapped at 80 columns.
+func (g *generator) printDoc(desc string) {
+	line := "//"
+	for _, w := range strings.Fields(desc) {
+		if len(line)+1+len(w) > 80 && line != "//" {
+			g.printf("%s\n", line)
+			line = "//"
+		}
+		line += " " + w
+	}
+	if line != "//" {
+		g.printf("%s\n", line)
+	}
+}
+
+// specType returns the dref spec type for a dataref, e.g. "Spec[float32]".
+func specType(ref dreftxt.DataRef) (string, bool) {
+	if ref.IsArray() {
+		switch ref.Type {
+		case "int":
+			return "ArraySpec[int32]", true
+		case "float":
+			return "ArraySpec[float32]", true
+		case "byte":
+			return "ArraySpec[byte]", true
+		}
+		return "", false
+	}
+	switch ref.Type {
+	case "int":
+		return "Spec[int]", true
+	case "float":
+		return "Spec[float32]", true
+	case "double":
+		return "Spec[float64]", true
+	}
+	return "", false
+}
+
+// describe summarizes the type, size, units and writability of a dataref.
+func describe(ref dreftxt.DataRef) string {
+	var parts []string
+	if ref.IsArray() {
+		if n := ref.Len(); n > 0 {
+			parts = append(parts, fmt.Sprintf("%s[%d]", ref.Type, n))
+		} else {
+			parts = append(parts, ref.Type+"[]")
+		}
+	} else {
+		parts = append(parts, ref.Type)
+	}
+	if u := units(ref.Units); u != "" {
+		parts = append(parts, "units: "+u)
+	}
+	if ref.Writable {
+		parts = append(parts, "writable")
+	} else {
+		parts = append(parts, "read-only")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// units drops the "???" placeholder used in DataRefs.txt for unknown units.
+func units(u string) string {
+	if strings.Trim(u, "?") == "" {
+		return ""
+	}
+	return u
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/akhenakh/xplane-go/dreftxt"
+)
+
+var (
+	testRefs = []dreftxt.DataRef{
+		{Name: "sim/flightmodel/position/latitude", Type: "double", Units: "degrees"},
+		{Name: "sim/flightmodel/engine/ENGN_N1_", Type: "float", Dims: []int{8}, Writable: true, Units: "percent"},
+	}
+	testCmds = []dreftxt.Command{
+		{Name: "sim/operation/pause_toggle", Description: "Toggle pause."},
+		{Name: "sim/operation/quit", Description: "Quit X-Plane."},
+	}
+)
+
+func generateFile(t *testing.T, fset *token.FileSet, prefixes ...string) *ast.File {
+	t.Helper()
+	g := &generator{pkg: "datarefs", trim: "sim/", prefixes: prefixes, idents: make(map[string]bool)}
+	src, err := g.generate(testRefs, testCmds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(fset, "datarefs.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	return f
+}
+
+func TestGenerateImportsDref(t *testing.T) {
+	f := generateFile(t, token.NewFileSet())
+	if len(f.Imports) != 1 || f.Imports[0].Path.Value != `"github.com/akhenakh/xplane-go/dref"` {
+		t.Errorf("imports = %v, want dref", f.Imports)
+	}
+}
+
+func TestGenerateCommandsOnly(t *testing.T) {
+	fset := token.NewFileSet()
+	f := generateFile(t, fset, "sim/operation/")
+	if len(f.Imports) != 0 {
+		t.Errorf("imports = %v, want none", f.Imports)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("datarefs", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("generated code does not compile: %v", err)
+	}
+}
+
+func TestGenerateNoMatch(t *testing.T) {
+	g := &generator{pkg: "datarefs", prefixes: []string{"laminar/"}, idents: make(map[string]bool)}
+	if _, err := g.generate(testRefs, testCmds); err == nil {
+		t.Error("generate succeeded with no matching names")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"unsafe"
 
 	"github.com/akhenakh/xplane-go/camera"
 	"github.com/akhenakh/xplane-go/dref"
@@ -19,6 +20,13 @@ func init() {
 	plugin.Register(&HelloPlugin{})
 }
 
+// positionSnapshot is filled from the simulator in a single call through a dref.Binding.
+type positionSnapshot struct {
+	Latitude  float64 `xp:"sim/flightmodel/position/latitude"`
+	Longitude float64 `xp:"sim/flightmodel/position/longitude"`
+	Altitude  float32 `xp:"sim/cockpit2/gauges/indicators/altitude_ft_pilot"`
+}
+
 // HelloPlugin holds the state for our plugin.
 type HelloPlugin struct {
 	ourMenu menu.MenuID
@@ -26,8 +34,11 @@ type HelloPlugin struct {
 	// A generic cache for all datarefs used by the plugin.
 	datarefCache *dref.DataRefCache
 
+	// Struct binding used by the position demo.
+	position *dref.Binding[positionSnapshot]
+
 	// Flight Loop ID for cleanup
-	ourFlightLoop processing.FlightLoopID
+	ourFlightLoop *processing.FlightLoop
 
 	// Camera Demo State
 	isCameraShaking bool
@@ -83,24 +94,30 @@ func (p *HelloPlugin) Enable() error {
 		"sim/flightmodel/position/local_x",
 		"sim/flightmodel/position/local_y",
 		"sim/flightmodel/position/local_z",
-		// For position demo
-		"sim/flightmodel/position/latitude",
-		"sim/flightmodel/position/longitude",
-		"sim/cockpit2/gauges/indicators/altitude_ft_pilot",
 	}
 
+	// Datarefs that are not published yet (e.g. by an aircraft plugin that loads
+	// later) stay pending instead of preventing the plugin from enabling.
+	// ReceiveMessage forwards X-Plane messages so the cache can retry them.
 	for _, name := range datarefsToRegister {
-		if err := p.datarefCache.Register(name); err != nil {
-			log.Printf("FATAL: Failed to register dataref '%s': %v", name, err)
-			return err // Fail to enable if a dataref is missing
+		if !p.datarefCache.RegisterPending(name, p.datarefReady) {
+			log.Printf("Dataref '%s' is not available yet, waiting for it to be published", name)
 		}
 	}
-	log.Println("Successfully initialized and registered all datarefs in cache.")
-	util.DebugString("Successfully initialized and registered all datarefs in cache.\n")
+	log.Println("Successfully initialized the dataref cache.")
+	util.DebugString("Successfully initialized the dataref cache.\n")
+
+	// The position demo reads all of its datarefs at once through a struct binding.
+	position, err := dref.Bind[positionSnapshot]()
+	if err != nil {
+		log.Printf("FATAL: Failed to bind position datarefs: %v", err)
+		return err
+	}
+	p.position = position
 
 	// Flight Loop Setup
-	p.ourFlightLoop = processing.CreateFlightLoop(processing.AfterFlightModel, p.flightLoopCallback)
-	processing.ScheduleFlightLoop(p.ourFlightLoop, 2.0, true)
+	p.ourFlightLoop = processing.NewFlightLoop(processing.AfterFlightModel, p.flightLoopCallback)
+	p.ourFlightLoop.Schedule(2.0, true)
 
 	return nil
 }
@@ -111,6 +128,18 @@ func (p *HelloPlugin) Disable() {
 	util.DebugString("HelloPlugin: Disabled!\n")
 }
 
+// ReceiveMessage implements plugin.MessageHandler.
+func (p *HelloPlugin) ReceiveMessage(from plugin.PluginID, msg plugin.Message, param unsafe.Pointer) {
+	if p.datarefCache != nil {
+		p.datarefCache.ReceiveMessage(from, msg, param)
+	}
+}
+
+// datarefReady is called by the cache once a pending dataref is registered.
+func (p *HelloPlugin) datarefReady(name string) {
+	util.DebugString(fmt.Sprintf("HelloPlugin: dataref '%s' is ready\n", name))
+}
+
 // flightLoopCallback is called by X-Plane at a regular interval.
 func (p *HelloPlugin) flightLoopCallback(elapsedSinceLastCall, elapsedTimeSinceLastFlightLoop float32, counter int) float32 {
 	zuluTime, err := p.datarefCache.GetFloat("sim/time/zulu_time_sec")
@@ -130,13 +159,11 @@ func (p *HelloPlugin) flightLoopCallback(elapsedSinceLastCall, elapsedTimeSinceL
 		newFOV = 80.0
 	}
 
-	// For setting a dataref, we still need to find it directly.
-	// The cache is primarily for optimized reading.
-	fovRef, err := dref.FindDataRef("sim/graphics/view/field_of_view_deg")
-	if err == nil {
-		dref.SetFloat(fovRef, newFOV)
-		util.DebugString(fmt.Sprintf("Go Plugin: Set FOV from %f to %f\n", currentFOV, newFOV))
+	if err := p.datarefCache.SetFloat("sim/graphics/view/field_of_view_deg", newFOV); err != nil {
+		util.DebugString(fmt.Sprintf("Go Plugin Error: %v\n", err))
+		return 2.0
 	}
+	util.DebugString(fmt.Sprintf("Go Plugin: Set FOV from %f to %f\n", currentFOV, newFOV))
 
 	return 2.0
 }
@@ -206,29 +233,20 @@ func (p *HelloPlugin) helloMenuHandler(menuRef, itemRef interface{}) {
 	}
 }
 
-// logCurrentPosition reads lat, lon, and alt from the cache and prints them.
+// logCurrentPosition reads lat, lon, and alt through the struct binding and prints them.
 func (p *HelloPlugin) logCurrentPosition() {
-	if p.datarefCache == nil {
-		log.Println("Error: Dataref cache is not initialized.")
-		util.DebugString("Error: Dataref cache is not initialized.\n")
+	if p.position == nil {
+		log.Println("Error: Position binding is not initialized.")
+		util.DebugString("Error: Position binding is not initialized.\n")
 		return
 	}
 
-	// Read the required datarefs from our cache
-	lat, errLat := p.datarefCache.GetDouble("sim/flightmodel/position/latitude")
-	lon, errLon := p.datarefCache.GetDouble("sim/flightmodel/position/longitude")
-	alt, errAlt := p.datarefCache.GetFloat("sim/cockpit2/gauges/indicators/altitude_ft_pilot")
-
-	// Check if any of the reads failed
-	if errLat != nil || errLon != nil || errAlt != nil {
-		errMsg := fmt.Sprintf("Error reading position datarefs: latErr=%v, lonErr=%v, altErr=%v", errLat, errLon, errAlt)
-		log.Println(errMsg)
-		util.DebugString(errMsg + "\n")
-		return
-	}
+	// Read all the position datarefs in one call
+	var pos positionSnapshot
+	p.position.Read(&pos)
 
-	// Format and log the successfully retrieved data
-	logMsg := fmt.Sprintf("Current Position -> Lat: %.4f, Lon: %.4f, Alt: %.0f ft", lat, lon, alt)
+	// Format and log the retrieved data
+	logMsg := fmt.Sprintf("Current Position -> Lat: %.4f, Lon: %.4f, Alt: %.0f ft", pos.Latitude, pos.Longitude, pos.Altitude)
 	log.Println(logMsg)
 	util.DebugString(logMsg + "\n")
 }
@@ -295,7 +313,7 @@ func (p *HelloPlugin) cleanup() {
 		p.ourMenu = nil
 	}
 	if p.ourFlightLoop != nil {
-		processing.DestroyFlightLoop(p.ourFlightLoop)
+		p.ourFlightLoop.Destroy()
 		p.ourFlightLoop = nil
 	}
 	if p.isCameraShaking {
@@ -303,6 +321,7 @@ func (p *HelloPlugin) cleanup() {
 		p.isCameraShaking = false
 	}
 	p.datarefCache = nil // Clear the cache reference
+	p.position = nil
 }
 
 // This empty main function is required by c-shared builds, but is not executed.
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var subcommands = []string{"get", "set", "watch", "cmd"}
+
+// complete prints to w the completions of the last word of args, the words
+// typed after "xpdref". Leading flags are skipped, and -datarefs is honoured.
+// The first word after them is a subcommand, the following ones are dataref
+// names, or command names after "cmd".
+func complete(w io.Writer, args []string) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	path := *datarefs
+	args, flagPath, ok := skipFlags(args)
+	if !ok {
+		// Completing a flag or its value.
+		return
+	}
+	if flagPath != "" {
+		path = flagPath
+	}
+	word := args[len(args)-1]
+	if len(args) == 1 {
+		printMatches(w, subcommands, word)
+		return
+	}
+	if args[0] == "set" && len(args)%2 == 1 {
+		// A value, not a name.
+		return
+	}
+	db, err := loadDatabase(path)
+	if err != nil || db == nil {
+		return
+	}
+	var names []string
+	if args[0] == "cmd" {
+		for _, c := range db.commands {
+			names = append(names, c.Name)
+		}
+	} else {
+		for _, r := range db.refs {
+			names = append(names, r.Name)
+		}
+	}
+	printMatches(w, names, word)
+}
+
+// skipFlags removes the global flags before the subcommand, returning the
+// value of -datarefs if given. It reports false when the last word is a flag
+// or a flag value.
+func skipFlags(args []string) (rest []string, datarefsPath string, ok bool) {
+	for len(args) > 1 && strings.HasPrefix(args[0], "-") {
+		if args[0] == "--" {
+			return args[1:], datarefsPath, true
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		args = args[1:]
+		if f := flag.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) {
+			if len(args) == 1 {
+				return nil, "", false
+			}
+			value, args = args[0], args[1:]
+		}
+		if name == "datarefs" {
+			datarefsPath = value
+		}
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return nil, "", false
+	}
+	return args, datarefsPath, true
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func printMatches(w io.Writer, names []string, prefix string) {
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			fmt.Fprintln(w, name)
+		}
+	}
+}
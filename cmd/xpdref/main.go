@@ -0,0 +1,227 @@
+// Command xpdref reads and writes datarefs and runs commands of a running
+// simulator over X-Plane's UDP protocol, without a plugin.
+//
+// Usage:
+//
+//	xpdref [flags] get NAME...
+//	xpdref [flags] set NAME VALUE [NAME VALUE]...
+//	xpdref [flags] watch [-rate HZ] NAME...
+//	xpdref [flags] cmd COMMAND...
+//	xpdref [flags] complete WORD...
+//
+// Names may end with an array index, e.g. "sim/flightmodel/engine/ENGN_N1_[0]".
+// When DataRefs.txt is given with -datarefs or $XPDREF_DATAREFS, values are
+// formatted by type, whole arrays can be read without an index, and names are
+// completed by "complete", which a shell completion function can call:
+//
+//	_xpdref() { COMPREPLY=($(xpdref complete "${COMP_WORDS[@]:1:COMP_CWORD}")); }
+//	complete -F _xpdref xpdref
+//
+// CommandRefs.txt is read from the same directory to complete commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/akhenakh/xplane-go/dreftxt"
+	"github.com/akhenakh/xplane-go/xpudp"
+)
+
+var (
+	address  = flag.String("addr", fmt.Sprintf("127.0.0.1:%d", xpudp.DefaultPort), "address of the simulator")
+	discover = flag.Bool("discover", false, "find the simulator on the local network from its beacon")
+	datarefs = flag.String("datarefs", os.Getenv("XPDREF_DATAREFS"), "path to DataRefs.txt (optional)")
+	timeout  = flag.Duration("timeout", 3*time.Second, "time to wait for the simulator")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: xpdref [flags] get|set|watch|cmd|complete ARGS...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("xpdref: ")
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if args[0] == "complete" {
+		// Never fail during completion, it would garble the shell.
+		complete(os.Stdout, args[1:])
+		return
+	}
+
+	db, err := loadDatabase(*datarefs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client, err := dial(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	switch args[0] {
+	case "get":
+		err = get(ctx, os.Stdout, client, db, args[1:])
+	case "set":
+		err = set(client, db, args[1:])
+	case "watch":
+		err = watch(ctx, client, db, args[1:])
+	case "cmd":
+		err = command(client, args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		client.Close()
+		log.Fatal(err)
+	}
+}
+
+func dial(ctx context.Context) (*xpudp.Client, error) {
+	addr := *address
+	if *discover {
+		ctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		beacon, err := xpudp.Discover(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("no simulator found: %w", err)
+		}
+		addr = beacon.Address()
+	}
+	return xpudp.Dial(addr, xpudp.ClientOptions{Timeout: *timeout})
+}
+
+func get(ctx context.Context, w io.Writer, client *xpudp.Client, db *database, names []string) error {
+	if len(names) == 0 {
+		return errors.New("get: no dataref names")
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	values := make([]*value, 0, len(names))
+	defer func() {
+		for _, v := range values {
+			v.unsubscribe()
+		}
+	}()
+	for _, name := range names {
+		v, err := subscribe(client, db, name, 10)
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+	}
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for _, v := range values {
+		for !v.received() {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("reading '%s': %w", v.name, ctx.Err())
+			case <-ticker.C:
+			}
+		}
+		if len(names) == 1 {
+			fmt.Fprintln(w, v.format())
+		} else {
+			fmt.Fprintf(w, "%s = %s\n", v.name, v.format())
+		}
+	}
+	return nil
+}
+
+func set(client *xpudp.Client, db *database, args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errors.New("set: expected NAME VALUE pairs")
+	}
+	for i := 0; i < len(args); i += 2 {
+		name := args[i]
+		v, err := strconv.ParseFloat(args[i+1], 32)
+		if err != nil {
+			return fmt.Errorf("set '%s': %w", name, err)
+		}
+		if ref := db.lookup(name); ref != nil && !ref.Writable {
+			// X-Plane silently ignores writes to read-only datarefs.
+			log.Printf("warning: '%s' is not writable", name)
+		}
+		if err := client.Set(name, float32(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func command(client *xpudp.Client, names []string) error {
+	if len(names) == 0 {
+		return errors.New("cmd: no command names")
+	}
+	for _, name := range names {
+		if err := client.Command(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// database holds the parsed DataRefs.txt and CommandRefs.txt. A nil database
+// knows nothing.
+type database struct {
+	refs     []dreftxt.DataRef
+	byName   map[string]*dreftxt.DataRef
+	commands []dreftxt.Command
+}
+
+// loadDatabase reads DataRefs.txt and, if present, CommandRefs.txt next to it.
+func loadDatabase(path string) (*database, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db := &database{}
+	if db.refs, err = dreftxt.ParseDataRefs(f); err != nil {
+		return nil, err
+	}
+	db.byName = make(map[string]*dreftxt.DataRef, len(db.refs))
+	for i := range db.refs {
+		db.byName[db.refs[i].Name] = &db.refs[i]
+	}
+	if f, err := os.Open(filepath.Join(filepath.Dir(path), "CommandRefs.txt")); err == nil {
+		defer f.Close()
+		db.commands, _ = dreftxt.ParseCommands(f)
+	}
+	return db, nil
+}
+
+// lookup returns the entry of a dataref, ignoring an array index, or nil.
+func (db *database) lookup(name string) *dreftxt.DataRef {
+	if db == nil {
+		return nil
+	}
+	base, _ := xpudp.SplitIndex(name)
+	return db.byName[base]
+}
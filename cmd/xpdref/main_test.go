@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhenakh/xplane-go/xpudp"
+	"github.com/akhenakh/xplane-go/xpudp/xpudptest"
+)
+
+const testDataRefs = "2 1200 Build 120000\n" +
+	"sim/cockpit/switches/gear_handle_status\tint\ty\tboolean\n" +
+	"sim/cockpit2/controls/flap_ratio\tfloat\ty\tratio\n" +
+	"sim/flightmodel/engine/ENGN_N1_\tfloat[2]\ty\tpercent\n" +
+	"sim/flightmodel/position/latitude\tdouble\tn\tdegrees\n"
+
+const testCommandRefs = "sim/flight_controls/flaps_down\tFlaps down a notch.\n" +
+	"sim/flight_controls/flaps_up\tFlaps up a notch.\n"
+
+func writeDatabase(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "DataRefs.txt")
+	if err := os.WriteFile(path, []byte(testDataRefs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "CommandRefs.txt"), []byte(testCommandRefs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestComplete(t *testing.T) {
+	path := writeDatabase(t)
+	defer func(old string) { *datarefs = old }(*datarefs)
+	*datarefs = path
+
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, subcommands},
+		{[]string{"s"}, []string{"set"}},
+		{[]string{"get", "sim/cockpit"}, []string{"sim/cockpit/switches/gear_handle_status", "sim/cockpit2/controls/flap_ratio"}},
+		{[]string{"get", "sim/flightmodel/position/latitude", "sim/flightmodel/e"}, []string{"sim/flightmodel/engine/ENGN_N1_"}},
+		{[]string{"set", "sim/cockpit2/controls/flap_ratio", "0"}, nil},
+		{[]string{"set", "sim/cockpit2/controls/flap_ratio", "0.5", "sim/cockpit2/c"}, []string{"sim/cockpit2/controls/flap_ratio"}},
+		{[]string{"cmd", "sim/flight_controls/flaps_"}, []string{"sim/flight_controls/flaps_down", "sim/flight_controls/flaps_up"}},
+		// Leading flags, as passed by the documented shell function.
+		{[]string{"-addr", "10.0.0.2:49000", "get", "sim/flightmodel/p"}, []string{"sim/flightmodel/position/latitude"}},
+		{[]string{"--timeout=1s", "-discover", "g"}, []string{"get"}},
+		{[]string{"-discover", "--", "c"}, []string{"cmd"}},
+		{[]string{"-addr", ""}, nil},
+		{[]string{"-tim"}, nil},
+	} {
+		var out strings.Builder
+		complete(&out, tc.args)
+		if got := strings.Fields(out.String()); !slices.Equal(got, tc.want) {
+			t.Errorf("complete(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+
+	// -datarefs among the typed words takes precedence.
+	*datarefs = ""
+	var out strings.Builder
+	complete(&out, []string{"-datarefs", path, "get", "sim/flightmodel/position/l"})
+	if got := strings.TrimSpace(out.String()); got != "sim/flightmodel/position/latitude" {
+		t.Errorf("complete with -datarefs = %q", got)
+	}
+}
+
+func newClient(t *testing.T) (*xpudptest.Server, *xpudp.Client) {
+	t.Helper()
+	server, err := xpudptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	client, err := xpudp.Dial(server.Address(), xpudp.ClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestGet(t *testing.T) {
+	db, err := loadDatabase(writeDatabase(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, client := newClient(t)
+	server.Set("sim/cockpit/switches/gear_handle_status", 1)
+	server.Set("sim/cockpit2/controls/flap_ratio", 0.25)
+	server.Set("sim/flightmodel/engine/ENGN_N1_", 85.5, 90)
+
+	var out strings.Builder
+	names := []string{"sim/cockpit/switches/gear_handle_status", "sim/cockpit2/controls/flap_ratio", "sim/flightmodel/engine/ENGN_N1_", "sim/flightmodel/engine/ENGN_N1_[1]"}
+	if err := get(context.Background(), &out, client, db, names); err != nil {
+		t.Fatal(err)
+	}
+	want := "sim/cockpit/switches/gear_handle_status = 1\n" +
+		"sim/cockpit2/controls/flap_ratio = 0.25\n" +
+		"sim/flightmodel/engine/ENGN_N1_ = [85.5 90]\n" +
+		"sim/flightmodel/engine/ENGN_N1_[1] = 90\n"
+	if out.String() != want {
+		t.Errorf("get printed\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := get(context.Background(), &out, client, nil, names[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "0.25\n" {
+		t.Errorf("get of one dataref printed %q", out.String())
+	}
+}
+
+func TestSetAndCommand(t *testing.T) {
+	server, client := newClient(t)
+	server.Set("sim/cockpit2/controls/flap_ratio", 0)
+	server.Set("sim/flightmodel/engine/ENGN_N1_", 0, 0)
+
+	if err := set(client, nil, []string{"sim/cockpit2/controls/flap_ratio", "0.5", "sim/flightmodel/engine/ENGN_N1_[1]", "70"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{nil, {"sim/cockpit2/controls/flap_ratio"}, {"sim/cockpit2/controls/flap_ratio", "half"}} {
+		if err := set(client, nil, args); err == nil {
+			t.Errorf("set(%q) succeeded", args)
+		}
+	}
+	if err := command(client, []string{"sim/flight_controls/flaps_down", "sim/flight_controls/flaps_up"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := command(client, nil); err == nil {
+		t.Error("command without names succeeded")
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		flaps, _ := server.Get("sim/cockpit2/controls/flap_ratio")
+		n1, _ := server.Get("sim/flightmodel/engine/ENGN_N1_")
+		commands := server.Commands()
+		if slices.Equal(flaps, []float32{0.5}) && slices.Equal(n1, []float32{0, 70}) && len(commands) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("simulator has flaps %v, N1 %v and commands %v", flaps, n1, commands)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
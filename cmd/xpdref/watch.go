@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akhenakh/xplane-go/dreftxt"
+	"github.com/akhenakh/xplane-go/xpudp"
+)
+
+// maxArrayElements bounds the elements subscribed for a whole array, as the
+// sizes in DataRefs.txt can be large.
+const maxArrayElements = 64
+
+// staleAfter is the age after which a watched value is marked stale.
+const staleAfter = 2 * time.Second
+
+// value is a subscribed dataref: a scalar or array element, or a whole array
+// when DataRefs.txt knows its size and the name has no index.
+type value struct {
+	name  string
+	ref   *dreftxt.DataRef
+	sub   *xpudp.Subscription
+	array *xpudp.ArraySubscription
+}
+
+func subscribe(client *xpudp.Client, db *database, name string, frequency int) (*value, error) {
+	v := &value{name: name, ref: db.lookup(name)}
+	if db != nil && v.ref == nil {
+		// X-Plane reports unknown datarefs as 0. Plugin datarefs are not
+		// listed either, so this is only a warning.
+		log.Printf("warning: '%s' is not in DataRefs.txt", name)
+	}
+	var err error
+	if _, index := xpudp.SplitIndex(name); index < 0 && v.ref != nil && v.ref.Len() > 0 {
+		v.array, err = client.SubscribeArray(name, min(v.ref.Len(), maxArrayElements), frequency)
+	} else {
+		v.sub, err = client.Subscribe(name, frequency, nil)
+	}
+	return v, err
+}
+
+func (v *value) unsubscribe() {
+	if v.array != nil {
+		v.array.Unsubscribe()
+	} else {
+		v.sub.Unsubscribe()
+	}
+}
+
+func (v *value) received() bool {
+	if v.array == nil {
+		_, ok := v.sub.Value()
+		return ok
+	}
+	for i := range v.array.Len() {
+		if _, ok := v.array.Element(i).Value(); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// updated returns the oldest update time of the value.
+func (v *value) updated() time.Time {
+	if v.array == nil {
+		return v.sub.Updated()
+	}
+	var oldest time.Time
+	for i := range v.array.Len() {
+		if t := v.array.Element(i).Updated(); i == 0 || t.Before(oldest) {
+			oldest = t
+		}
+	}
+	return oldest
+}
+
+// format formats the value by its type in DataRefs.txt, or as a float.
+func (v *value) format() string {
+	integer := v.ref != nil && (v.ref.Type == "int" || v.ref.Type == "byte")
+	if v.array == nil {
+		return formatFloat(v.sub.Float(), integer)
+	}
+	if v.ref.Type == "byte" {
+		return strconv.Quote(v.array.String())
+	}
+	parts := make([]string, v.array.Len())
+	for i, f := range v.array.Floats() {
+		parts[i] = formatFloat(f, integer)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func formatFloat(f float32, integer bool) string {
+	if integer {
+		return strconv.Itoa(int(f))
+	}
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+// watch prints a table of values until interrupted. On a terminal the table
+// is redrawn in place; otherwise one line of values is printed per refresh,
+// for logs.
+func watch(ctx context.Context, client *xpudp.Client, db *database, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	rate := flags.Int("rate", 10, "subscription and refresh rate in Hz")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("watch: no dataref names")
+	}
+	if *rate <= 0 {
+		return errors.New("watch: rate must be positive")
+	}
+
+	values := make([]*value, 0, flags.NArg())
+	defer func() {
+		for _, v := range values {
+			v.unsubscribe()
+		}
+	}()
+	for _, name := range flags.Args() {
+		v, err := subscribe(client, db, name, *rate)
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+	}
+
+	terminal := isTerminal(os.Stdout)
+	ticker := time.NewTicker(time.Second / time.Duration(min(*rate, 30)))
+	defer ticker.Stop()
+	lines := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if terminal {
+			lines = drawTable(values, lines, client.Connected())
+		} else {
+			printLine(values)
+		}
+	}
+}
+
+// drawTable draws the table over the previous one, which had lines lines,
+// and returns the number of lines drawn.
+func drawTable(values []*value, lines int, connected bool) int {
+	var b strings.Builder
+	if lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", lines)
+	}
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE\tAGE\x1b[K")
+	now := time.Now()
+	for _, v := range values {
+		age := "-"
+		if v.received() {
+			age = now.Sub(v.updated()).Truncate(time.Millisecond).String()
+			if now.Sub(v.updated()) > staleAfter {
+				age += " (stale)"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\x1b[K\n", v.name, v.format(), age)
+	}
+	w.Flush()
+	status := "connected"
+	if !connected {
+		status = "waiting for the simulator..."
+	}
+	fmt.Fprintf(&b, "%s\x1b[K\n", status)
+	os.Stdout.WriteString(b.String())
+	return len(values) + 2
+}
+
+func printLine(values []*value) {
+	var b strings.Builder
+	b.WriteString(time.Now().Format(time.RFC3339Nano))
+	for _, v := range values {
+		fmt.Fprintf(&b, " %s=%s", v.name, v.format())
+	}
+	b.WriteByte('\n')
+	os.Stdout.WriteString(b.String())
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
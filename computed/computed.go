@@ -0,0 +1,262 @@
+// Package computed publishes virtual datarefs whose value is computed from
+// other datarefs by an expression, e.g.
+//
+//	myplugin/agl_ft = sim/flightmodel/position/y_agl * 3.28084
+//
+// Expressions are evaluated lazily, each time X-Plane or another plugin reads
+// the dataref.
+package computed
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/plugin"
+)
+
+var (
+	ErrAlreadyDefined = errors.New("computed dataref already defined")
+	ErrNotDefined     = errors.New("computed dataref not defined")
+	ErrNotArray       = errors.New("dataref is not an array")
+	ErrNotScalar      = errors.New("dataref is an array and needs an index or sum()/avg()")
+)
+
+// input is a dataref read by one or more expressions.
+type input struct {
+	name   string
+	ref    dref.DataRef
+	types  dref.DataType
+	ints   []int32
+	floats []float32
+}
+
+func (in *input) scalar() float64 {
+	switch {
+	case in.types&dref.TypeDouble != 0:
+		return dref.GetDouble(in.ref)
+	case in.types&dref.TypeFloat != 0:
+		return float64(dref.GetFloat(in.ref))
+	default:
+		return float64(dref.GetInt(in.ref))
+	}
+}
+
+func (in *input) element(i int) float64 {
+	if in.types&dref.TypeFloatArray != 0 {
+		var v [1]float32
+		dref.GetFloatArray(in.ref, v[:], i)
+		return float64(v[0])
+	}
+	var v [1]int32
+	dref.GetIntArray(in.ref, v[:], i)
+	return float64(v[0])
+}
+
+// all appends every element of the array to dst.
+func (in *input) all(dst []float64) []float64 {
+	if in.types&dref.TypeFloatArray != 0 {
+		in.floats = slices.Grow(in.floats[:0], dref.FloatArrayLen(in.ref))
+		in.floats = in.floats[:cap(in.floats)]
+		for _, v := range in.floats[:dref.GetFloatArray(in.ref, in.floats, 0)] {
+			dst = append(dst, float64(v))
+		}
+		return dst
+	}
+	in.ints = slices.Grow(in.ints[:0], dref.IntArrayLen(in.ref))
+	in.ints = in.ints[:cap(in.ints)]
+	for _, v := range in.ints[:dref.GetIntArray(in.ref, in.ints, 0)] {
+		dst = append(dst, float64(v))
+	}
+	return dst
+}
+
+// Definition is a computed dataref published by a Registry.
+type Definition struct {
+	Name string
+	Expr *Expr
+	ref  dref.DataRef
+}
+
+// Registry holds the computed datarefs of a plugin. It is closed when the
+// plugin is disabled, and can be used again once it is re-enabled.
+type Registry struct {
+	defs   []*Definition
+	inputs map[string]*input
+	mutex  sync.Mutex
+}
+
+// Registries holding definitions, closed when the plugin is disabled.
+var (
+	active      = make(map[*Registry]bool)
+	activeMutex sync.Mutex
+)
+
+func init() {
+	plugin.OnDisable(func() {
+		activeMutex.Lock()
+		registries := make([]*Registry, 0, len(active))
+		for r := range active {
+			registries = append(registries, r)
+		}
+		activeMutex.Unlock()
+		for _, r := range registries {
+			r.Close()
+		}
+	})
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{inputs: make(map[string]*input)}
+}
+
+// Define parses expr and publishes it as the read-only dataref name, with
+// int, float and double types. Every dataref the expression uses must exist;
+// computed datarefs defined earlier can be used like any other.
+func (r *Registry) Define(name, expr string) (*Definition, error) {
+	e, err := Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("computed dataref '%s': %w", name, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.find(name) >= 0 {
+		return nil, fmt.Errorf("computed dataref '%s': %w", name, ErrAlreadyDefined)
+	}
+	for _, ref := range e.refs {
+		if ref.in, err = r.bind(ref); err != nil {
+			return nil, fmt.Errorf("computed dataref '%s': %w", name, err)
+		}
+	}
+
+	def := &Definition{Name: name, Expr: e}
+	def.ref, err = dref.Publish(name, dref.Accessor{
+		GetInt:    func() int { return int(e.Eval()) },
+		GetFloat:  func() float32 { return float32(e.Eval()) },
+		GetDouble: e.Eval,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("computed dataref '%s': %w", name, err)
+	}
+	r.defs = append(r.defs, def)
+	activeMutex.Lock()
+	active[r] = true
+	activeMutex.Unlock()
+	return def, nil
+}
+
+// DefineLine parses a definition of the form "name = expression".
+func (r *Registry) DefineLine(line string) (*Definition, error) {
+	name, expr, ok := strings.Cut(line, "=")
+	// Do not split on a comparison such as "a == b" or "a >= b".
+	if !ok || strings.HasPrefix(expr, "=") || strings.HasSuffix(name, "!") ||
+		strings.HasSuffix(name, "<") || strings.HasSuffix(name, ">") {
+		return nil, fmt.Errorf("%w: expected \"name = expression\", got %q", ErrSyntax, line)
+	}
+	return r.Define(strings.TrimSpace(name), strings.TrimSpace(expr))
+}
+
+// Load defines one computed dataref per line of rd. Blank lines and lines
+// starting with # are ignored. Loading stops at the first invalid line.
+func (r *Registry) Load(rd io.Reader) error {
+	scanner := bufio.NewScanner(rd)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := r.DefineLine(line); err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Value evaluates a computed dataref without going through X-Plane. Like any
+// dataref read, it must be called on the simulator thread.
+func (r *Registry) Value(name string) (float64, error) {
+	r.mutex.Lock()
+	i := r.find(name)
+	var def *Definition
+	if i >= 0 {
+		def = r.defs[i]
+	}
+	r.mutex.Unlock()
+	if def == nil {
+		return math.NaN(), fmt.Errorf("computed dataref '%s': %w", name, ErrNotDefined)
+	}
+	return def.Expr.Eval(), nil
+}
+
+// Definitions returns the computed datarefs in the order they were defined.
+func (r *Registry) Definitions() []*Definition {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slices.Clone(r.defs)
+}
+
+// Remove unpublishes a computed dataref.
+func (r *Registry) Remove(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	i := r.find(name)
+	if i < 0 {
+		return fmt.Errorf("computed dataref '%s': %w", name, ErrNotDefined)
+	}
+	err := dref.Unpublish(r.defs[i].ref)
+	r.defs = slices.Delete(r.defs, i, i+1)
+	return err
+}
+
+// Close unpublishes every computed dataref of the registry, most recent
+// first, since later definitions may read earlier ones, and forgets the
+// datarefs they read.
+func (r *Registry) Close() {
+	r.mutex.Lock()
+	defs := r.defs
+	r.defs = nil
+	r.inputs = make(map[string]*input)
+	r.mutex.Unlock()
+	activeMutex.Lock()
+	delete(active, r)
+	activeMutex.Unlock()
+	for _, def := range slices.Backward(defs) {
+		dref.Unpublish(def.ref)
+	}
+}
+
+func (r *Registry) find(name string) int {
+	return slices.IndexFunc(r.defs, func(d *Definition) bool { return d.Name == name })
+}
+
+// bind looks up the dataref read by ref and checks that it is used with the
+// right shape.
+func (r *Registry) bind(ref *refNode) (*input, error) {
+	in := r.inputs[ref.name]
+	if in == nil {
+		handle, err := dref.FindDataRef(ref.name)
+		if err != nil {
+			return nil, fmt.Errorf("dataref '%s': %w", ref.name, err)
+		}
+		in = &input{name: ref.name, ref: handle, types: dref.GetDataRefTypes(handle)}
+		r.inputs[ref.name] = in
+	}
+
+	isArray := in.types&(dref.TypeIntArray|dref.TypeFloatArray) != 0
+	isScalar := in.types&(dref.TypeInt|dref.TypeFloat|dref.TypeDouble) != 0
+	switch {
+	case (ref.whole || ref.index >= 0) && !isArray:
+		return nil, fmt.Errorf("dataref '%s': %w", ref.name, ErrNotArray)
+	case !ref.whole && ref.index < 0 && !isScalar:
+		return nil, fmt.Errorf("dataref '%s': %w", ref.name, ErrNotScalar)
+	}
+	return in, nil
+}
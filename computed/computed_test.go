@@ -0,0 +1,122 @@
+package computed
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+	"github.com/akhenakh/xplane-go/plugin"
+)
+
+func addInputs(t *testing.T) {
+	t.Helper()
+	xplmfake.AddDataRef("test/computed/agl", xplmfake.TypeFloat, true)
+	xplmfake.AddDataRef("test/computed/fuel", xplmfake.TypeFloatArray, true)
+	agl, _ := dref.FindDataRef("test/computed/agl")
+	dref.SetFloat(agl, 100)
+	fuel, _ := dref.FindDataRef("test/computed/fuel")
+	dref.SetFloatArray(fuel, []float32{10, 20, 30}, 0)
+}
+
+func TestRegistry(t *testing.T) {
+	addInputs(t)
+	r := NewRegistry()
+	defer r.Close()
+
+	err := r.Load(strings.NewReader(`# Converted and derived values
+test/computed/agl_ft = test/computed/agl * 3
+test/computed/tank2 = test/computed/fuel[1]
+
+test/computed/total = sum(test/computed/fuel) + test/computed/agl_ft
+test/computed/high = test/computed/agl_ft >= 300
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]float64{
+		"test/computed/agl_ft": 300,
+		"test/computed/tank2":  20,
+		"test/computed/total":  60 + 300,
+		"test/computed/high":   1,
+	} {
+		if got, err := r.Value(name); err != nil || got != want {
+			t.Errorf("Value(%s) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	// Other plugins read the published datarefs through X-Plane.
+	ref, err := dref.FindDataRef("test/computed/total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dref.GetDouble(ref); got != 360 {
+		t.Errorf("published total = %v, want 360", got)
+	}
+	if got := len(r.Definitions()); got != 4 {
+		t.Errorf("%d definitions, want 4", got)
+	}
+
+	if _, err := r.Define("test/computed/tank2", "1"); !errors.Is(err, ErrAlreadyDefined) {
+		t.Errorf("redefining: err = %v, want ErrAlreadyDefined", err)
+	}
+	if err := r.Remove("test/computed/high"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Value("test/computed/high"); !errors.Is(err, ErrNotDefined) {
+		t.Errorf("Value after Remove: err = %v, want ErrNotDefined", err)
+	}
+	if err := r.Remove("test/computed/high"); !errors.Is(err, ErrNotDefined) {
+		t.Errorf("second Remove: err = %v, want ErrNotDefined", err)
+	}
+}
+
+func TestDefineErrors(t *testing.T) {
+	addInputs(t)
+	r := NewRegistry()
+	defer r.Close()
+
+	for _, tc := range []struct {
+		line string
+		want error
+	}{
+		{"test/computed/bad = test/computed/fuel * 2", ErrNotScalar},
+		{"test/computed/bad = test/computed/agl[0]", ErrNotArray},
+		{"test/computed/bad = sum(test/computed/agl)", ErrNotArray},
+		{"test/computed/bad = test/computed/nothing", dref.ErrDataRefNotFound},
+		{"test/computed/bad = 1 +", ErrSyntax},
+		{"test/computed/bad == 1", ErrSyntax},
+		{"test/computed/bad", ErrSyntax},
+	} {
+		if _, err := r.DefineLine(tc.line); !errors.Is(err, tc.want) {
+			t.Errorf("DefineLine(%q): err = %v, want %v", tc.line, err, tc.want)
+		}
+	}
+	err := r.Load(strings.NewReader("test/computed/ok = 1\n\ntest/computed/bad = (\n"))
+	if !errors.Is(err, ErrSyntax) || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Errorf("Load: err = %v, want a syntax error on line 3", err)
+	}
+}
+
+func TestRegistryAfterDisable(t *testing.T) {
+	addInputs(t)
+	r := NewRegistry()
+	const name = "test/computed/reenabled"
+	if _, err := r.Define(name, "test/computed/agl + 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin.XPluginDisable()
+	if len(r.Definitions()) != 0 {
+		t.Error("definitions kept after disable")
+	}
+	if _, err := r.Define(name, "test/computed/agl + 2"); err != nil {
+		t.Fatalf("Define after re-enable: %v", err)
+	}
+	if got, _ := r.Value(name); got != 102 {
+		t.Errorf("Value = %v, want 102", got)
+	}
+	if err := r.Remove(name); err != nil {
+		t.Errorf("Remove after re-enable: %v", err)
+	}
+}
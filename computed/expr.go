@@ -0,0 +1,518 @@
+package computed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrSyntax          = errors.New("syntax error")
+	ErrUnknownFunction = errors.New("unknown function")
+	ErrArgumentCount   = errors.New("wrong number of arguments")
+)
+
+// Expr is a parsed expression. Operands are numbers, dataref names and
+// function calls, combined with the usual arithmetic and comparison operators:
+//
+//	sim/flightmodel/position/y_agl * 3.28084
+//	sum(sim/flightmodel/weight/m_fuel) / 0.8
+//	if(sim/cockpit2/gauges/indicators/airspeed_kts_pilot > 40, 1, 0)
+//
+// Since dataref names contain slashes, a division by a dataref must be
+// written with spaces around the operator: "a / sim/some/ref".
+// Comparisons and the && and || operators return 1 for true and 0 for false.
+// An array element is read with name[index].
+type Expr struct {
+	src  string
+	root node
+	refs []*refNode
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// DataRefs returns the names of the datarefs used by the expression, in order
+// of appearance and without duplicates.
+func (e *Expr) DataRefs() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, r := range e.refs {
+		if !seen[r.name] {
+			seen[r.name] = true
+			names = append(names, r.name)
+		}
+	}
+	return names
+}
+
+// Eval evaluates the expression. The datarefs must have been bound.
+func (e *Expr) Eval() float64 {
+	return e.root.eval()
+}
+
+type node interface {
+	eval() float64
+}
+
+type numberNode float64
+
+func (n numberNode) eval() float64 { return float64(n) }
+
+// refNode reads a dataref: the whole value when index is -1, one element
+// otherwise. In sum() and avg() it stands for every element of an array.
+type refNode struct {
+	name  string
+	index int
+	whole bool
+	in    *input
+}
+
+func (n *refNode) eval() float64 {
+	if n.index >= 0 {
+		return n.in.element(n.index)
+	}
+	return n.in.scalar()
+}
+
+type unaryNode struct {
+	op rune
+	x  node
+}
+
+func (n *unaryNode) eval() float64 {
+	v := n.x.eval()
+	switch n.op {
+	case '-':
+		return -v
+	case '!':
+		return truth(v == 0)
+	}
+	return v
+}
+
+type binaryNode struct {
+	op   string
+	x, y node
+}
+
+func (n *binaryNode) eval() float64 {
+	x := n.x.eval()
+	// && and || short-circuit like in Go.
+	switch n.op {
+	case "&&":
+		return truth(x != 0 && n.y.eval() != 0)
+	case "||":
+		return truth(x != 0 || n.y.eval() != 0)
+	}
+	y := n.y.eval()
+	switch n.op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		return x / y
+	case "%":
+		return math.Mod(x, y)
+	case "^":
+		return math.Pow(x, y)
+	case "<":
+		return truth(x < y)
+	case "<=":
+		return truth(x <= y)
+	case ">":
+		return truth(x > y)
+	case ">=":
+		return truth(x >= y)
+	case "==":
+		return truth(x == y)
+	case "!=":
+		return truth(x != y)
+	}
+	return math.NaN()
+}
+
+type callNode struct {
+	fn   *function
+	args []node
+	buf  []float64
+}
+
+func (n *callNode) eval() float64 {
+	if n.fn.array != nil {
+		n.buf = n.args[0].(*refNode).in.all(n.buf[:0])
+		return n.fn.array(n.buf)
+	}
+	if n.fn.lazy != nil {
+		return n.fn.lazy(n.args)
+	}
+	n.buf = n.buf[:0]
+	for _, a := range n.args {
+		n.buf = append(n.buf, a.eval())
+	}
+	return n.fn.call(n.buf)
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// function is a built-in function. Exactly one of call, lazy and array is
+// set: array functions take a whole array dataref, lazy ones evaluate their
+// own arguments.
+type function struct {
+	minArgs, maxArgs int // maxArgs < 0 means variadic.
+	call             func(args []float64) float64
+	lazy             func(args []node) float64
+	array            func(values []float64) float64
+}
+
+// arity describes the number of arguments the function takes.
+func (fn *function) arity() string {
+	switch {
+	case fn.maxArgs < 0:
+		return fmt.Sprintf("at least %d", fn.minArgs)
+	case fn.minArgs == fn.maxArgs:
+		return fmt.Sprint(fn.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d", fn.minArgs, fn.maxArgs)
+	}
+}
+
+func unary(f func(float64) float64) *function {
+	return &function{minArgs: 1, maxArgs: 1, call: func(a []float64) float64 { return f(a[0]) }}
+}
+
+var functions = map[string]*function{
+	"abs":   unary(math.Abs),
+	"sqrt":  unary(math.Sqrt),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"round": unary(math.Round),
+	"sin":   unary(func(x float64) float64 { return math.Sin(x * math.Pi / 180) }),
+	"cos":   unary(func(x float64) float64 { return math.Cos(x * math.Pi / 180) }),
+	"tan":   unary(func(x float64) float64 { return math.Tan(x * math.Pi / 180) }),
+	"asin":  unary(func(x float64) float64 { return math.Asin(x) * 180 / math.Pi }),
+	"acos":  unary(func(x float64) float64 { return math.Acos(x) * 180 / math.Pi }),
+	"atan":  unary(func(x float64) float64 { return math.Atan(x) * 180 / math.Pi }),
+	"atan2": {minArgs: 2, maxArgs: 2, call: func(a []float64) float64 {
+		return math.Atan2(a[0], a[1]) * 180 / math.Pi
+	}},
+	"pow": {minArgs: 2, maxArgs: 2, call: func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min": {minArgs: 1, maxArgs: -1, call: func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {minArgs: 1, maxArgs: -1, call: func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+	"clamp": {minArgs: 3, maxArgs: 3, call: func(a []float64) float64 {
+		return math.Max(a[1], math.Min(a[2], a[0]))
+	}},
+	"if": {minArgs: 3, maxArgs: 3, lazy: func(a []node) float64 {
+		if a[0].eval() != 0 {
+			return a[1].eval()
+		}
+		return a[2].eval()
+	}},
+	"sum": {minArgs: 1, maxArgs: 1, array: func(v []float64) float64 {
+		s := 0.0
+		for _, x := range v {
+			s += x
+		}
+		return s
+	}},
+	"avg": {minArgs: 1, maxArgs: 1, array: func(v []float64) float64 {
+		if len(v) == 0 {
+			return 0
+		}
+		s := 0.0
+		for _, x := range v {
+			s += x
+		}
+		return s / float64(len(v))
+	}},
+}
+
+var constants = map[string]float64{
+	"pi": math.Pi,
+}
+
+// Parse parses an expression. Datarefs are not looked up until the
+// expression is bound by a Registry.
+func Parse(src string) (*Expr, error) {
+	p := &parser{src: src}
+	p.next()
+	root, err := p.parseExpr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %q", p.tok.text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: src, root: root, refs: p.refs}, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokName
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src  string
+	pos  int
+	tok  token
+	refs []*refNode
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at column %d: %s", ErrSyntax, p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+func isNameStart(r byte) bool {
+	return r == '_' || unicode.IsLetter(rune(r))
+}
+
+func isNamePart(r byte) bool {
+	return isNameStart(r) || (r >= '0' && r <= '9')
+}
+
+// next scans the next token.
+func (p *parser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (isNamePart(p.src[p.pos]) || p.src[p.pos] == '.' ||
+			(p.src[p.pos] == '-' || p.src[p.pos] == '+') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E')) {
+			p.pos++
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], pos: start}
+	case isNameStart(c):
+		// A slash directly followed by a letter continues a dataref name.
+		for p.pos < len(p.src) && (isNamePart(p.src[p.pos]) ||
+			p.src[p.pos] == '/' && p.pos+1 < len(p.src) && isNameStart(p.src[p.pos+1])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, text: p.src[start:p.pos], pos: start}
+	default:
+		p.pos++
+		if p.pos < len(p.src) {
+			switch two := p.src[start : p.pos+1]; two {
+			case "<=", ">=", "==", "!=", "&&", "||":
+				p.pos++
+			}
+		}
+		p.tok = token{kind: tokOp, text: p.src[start:p.pos], pos: start}
+	}
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	if p.tok.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		if p.tok.kind == tokEOF {
+			return p.errorf("missing %q", op)
+		}
+		return p.errorf("expected %q, found %q", op, p.tok.text)
+	}
+	p.next()
+	return nil
+}
+
+// Operator precedence, lowest first.
+var levels = [][]string{
+	{"||"},
+	{"&&"},
+	{"<", "<=", ">", ">=", "==", "!="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseExpr() (node, error) {
+	return p.parseLevel(0)
+}
+
+func (p *parser) parseLevel(level int) (node, error) {
+	if level == len(levels) {
+		return p.parseUnary()
+	}
+	x, err := p.parseLevel(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(levels[level]...) {
+		op := p.tok.text
+		p.next()
+		y, err := p.parseLevel(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-", "+", "!") {
+		op := rune(p.tok.text[0])
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, x: x}, nil
+	}
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("^") {
+		p.next()
+		// Right-associative, and binds tighter than unary minus on its left.
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: "^", x: x, y: y}, nil
+	}
+	return x, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	switch p.tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(p.tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.tok.text)
+		}
+		p.next()
+		return numberNode(v), nil
+	case tokName:
+		return p.parseName()
+	case tokOp:
+		if p.tok.text == "(" {
+			p.next()
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return nil, p.errorf("unexpected end of expression")
+}
+
+func (p *parser) parseName() (node, error) {
+	name, pos := p.tok.text, p.tok.pos
+	p.next()
+
+	if !strings.Contains(name, "/") {
+		if p.isOp("(") {
+			return p.parseCall(name, pos)
+		}
+		if v, ok := constants[name]; ok {
+			return numberNode(v), nil
+		}
+		return nil, fmt.Errorf("%w at column %d: unknown name %q", ErrSyntax, pos+1, name)
+	}
+
+	ref := &refNode{name: name, index: -1}
+	if p.isOp("[") {
+		p.next()
+		if p.tok.kind != tokNumber {
+			return nil, p.errorf("array index must be a number")
+		}
+		index, err := strconv.Atoi(p.tok.text)
+		if err != nil || index < 0 {
+			return nil, p.errorf("invalid array index %q", p.tok.text)
+		}
+		ref.index = index
+		p.next()
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	p.refs = append(p.refs, ref)
+	return ref, nil
+}
+
+func (p *parser) parseCall(name string, pos int) (node, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w at column %d: %q", ErrUnknownFunction, pos+1, name)
+	}
+	p.next() // (
+	var args []node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next() // )
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%w at column %d: %s takes %s, got %d", ErrArgumentCount, pos+1, name, fn.arity(), len(args))
+	}
+	if fn.array != nil {
+		ref, ok := args[0].(*refNode)
+		if !ok || ref.index >= 0 {
+			return nil, fmt.Errorf("%w at column %d: %s takes an array dataref", ErrSyntax, pos+1, name)
+		}
+		ref.whole = true
+	}
+	return &callNode{fn: fn, args: args}, nil
+}
@@ -0,0 +1,114 @@
+package computed
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"64 / 4 / 2", 8},
+		{"7 % 4 * 2", 6},
+		{"2 ^ 3 ^ 2", 512},
+		{"2 * 3 ^ 2", 18},
+		{"-2 ^ 2", -4},
+		{"2 ^ -1", 0.5},
+		{"(-2) ^ 2", 4},
+		{"--3", 3},
+		{"-3 + +1", -2},
+		{"!0 + !5", 1},
+		{"1.5e3 + 2.5E-1", 1500.25},
+		{"pi", math.Pi},
+		{"1 + 2 == 3", 1},
+		{"1 < 2 && 2 <= 2 && 3 > 2 && 3 >= 4", 0},
+		{"1 != 1 || 2 == 2", 1},
+		{"0 || 0", 0},
+		{"1 + 1 > 1 == 1", 1},
+		{"if(1 > 2, 10, 20)", 20},
+		{"if(3, 10, 1/0)", 10},
+		{"abs(-3) + sqrt(16)", 7},
+		{"floor(2.7) + ceil(2.2) + round(2.5)", 8},
+		{"sin(90) + cos(0)", 2},
+		{"atan2(1, 1)", 45},
+		{"pow(2, 10)", 1024},
+		{"min(3, 1, 2) + max(4)", 5},
+		{"clamp(15, 0, 10) + clamp(-5, 0, 10)", 10},
+	} {
+		e, err := Parse(tc.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.src, err)
+			continue
+		}
+		if got := e.Eval(); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestDataRefs(t *testing.T) {
+	e, err := Parse("sim/a * 2 + sum(sim/b) - sim/a + sim/c[3] / sim/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(e.DataRefs(), " "); got != "sim/a sim/b sim/c sim/d" {
+		t.Errorf("DataRefs() = %s", got)
+	}
+	if e.String() != "sim/a * 2 + sum(sim/b) - sim/a + sim/c[3] / sim/d" {
+		t.Errorf("String() = %s", e.String())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want error
+		msg  string
+	}{
+		{"1 +", ErrSyntax, "column 4: unexpected end of expression"},
+		{"(1 + 2", ErrSyntax, `column 7: missing ")"`},
+		{"1 2", ErrSyntax, `column 3: unexpected "2"`},
+		{"* 2", ErrSyntax, `column 1: unexpected "*"`},
+		{"2 + foo", ErrSyntax, `column 5: unknown name "foo"`},
+		{"1.2.3", ErrSyntax, `column 1: invalid number "1.2.3"`},
+		{"sim/a[x]", ErrSyntax, "column 7: array index must be a number"},
+		{"sim/a[1.5]", ErrSyntax, `column 7: invalid array index "1.5"`},
+		{"sim/a[1", ErrSyntax, `column 8: missing "]"`},
+		{"max(1 2)", ErrSyntax, `column 7: expected ",", found "2"`},
+		{"sum(sim/a[0])", ErrSyntax, "column 1: sum takes an array dataref"},
+		{"avg(2)", ErrSyntax, "column 1: avg takes an array dataref"},
+		{"1 + nope(2)", ErrUnknownFunction, `column 5: "nope"`},
+		{"sqrt()", ErrArgumentCount, "column 1: sqrt takes 1, got 0"},
+		{"atan2(1)", ErrArgumentCount, "column 1: atan2 takes 2, got 1"},
+		{"1 + if(1, 2)", ErrArgumentCount, "column 5: if takes 3, got 2"},
+		{"min()", ErrArgumentCount, "column 1: min takes at least 1, got 0"},
+		{"sum(sim/a, sim/b)", ErrArgumentCount, "column 1: sum takes 1, got 2"},
+	} {
+		_, err := Parse(tc.src)
+		if !errors.Is(err, tc.want) || !strings.HasSuffix(err.Error(), tc.msg) {
+			t.Errorf("Parse(%q): err = %v, want %v ending with %q", tc.src, err, tc.want, tc.msg)
+		}
+	}
+}
+
+func TestArity(t *testing.T) {
+	for _, tc := range []struct {
+		fn   function
+		want string
+	}{
+		{function{minArgs: 1, maxArgs: 1}, "1"},
+		{function{minArgs: 1, maxArgs: 3}, "1 to 3"},
+		{function{minArgs: 2, maxArgs: -1}, "at least 2"},
+	} {
+		if got := tc.fn.arity(); got != tc.want {
+			t.Errorf("arity(%d, %d) = %q, want %q", tc.fn.minArgs, tc.fn.maxArgs, got, tc.want)
+		}
+	}
+}
@@ -11,6 +11,8 @@ import "C"
 import (
 	"sync"
 	"unsafe"
+
+	"github.com/akhenakh/xplane-go/util"
 )
 
 type WidgetID C.XPWidgetID
@@ -45,10 +47,18 @@ func getWidgetCallback(id uintptr) WidgetFunc {
 	return widgetCallbackRegistry[id]
 }
 
+func unregisterWidgetCallback(id uintptr) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	delete(widgetCallbackRegistry, id)
+}
+
 //export widgetCallback_cgo
 func widgetCallback_cgo(inMessage C.XPWidgetMessage, inWidget C.XPWidgetID, inParam1 C.intptr_t, inParam2 C.intptr_t) C.int {
 	id := uintptr(C.XPGetWidgetProperty(inWidget, C.xPProperty_Refcon, nil))
 	if callback := getWidgetCallback(id); callback != nil {
+		// A panicking callback is removed and the message left unhandled.
+		defer util.RecoverPanic("widget callback", func() { unregisterWidgetCallback(id) })
 		handled := callback(
 			WidgetMessage(inMessage),
 			WidgetID(inWidget),
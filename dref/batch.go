@@ -0,0 +1,344 @@
+package dref
+
+// #cgo CFLAGS: -DXPLM410=1
+// #include <stdlib.h>
+// #include "XPLMDataAccess.h"
+//
+// typedef struct {
+// 	XPLMDataRef ref;
+// 	int type;
+// 	int offset;
+// 	int count;
+// 	int dirty;
+// 	size_t pos;
+// } batch_entry;
+//
+// static void batch_read(const batch_entry* e, int n, char* buf) {
+// 	for (int i = 0; i < n; i++, e++) {
+// 		char* p = buf + e->pos;
+// 		switch (e->type) {
+// 		case xplmType_Int:        *(int*)p = XPLMGetDatai(e->ref); break;
+// 		case xplmType_Float:      *(float*)p = XPLMGetDataf(e->ref); break;
+// 		case xplmType_Double:     *(double*)p = XPLMGetDatad(e->ref); break;
+// 		case xplmType_IntArray:   XPLMGetDatavi(e->ref, (int*)p, e->offset, e->count); break;
+// 		case xplmType_FloatArray: XPLMGetDatavf(e->ref, (float*)p, e->offset, e->count); break;
+// 		case xplmType_Data:       XPLMGetDatab(e->ref, p, e->offset, e->count); break;
+// 		}
+// 	}
+// }
+//
+// static void batch_write(batch_entry* e, int n, char* buf) {
+// 	for (int i = 0; i < n; i++, e++) {
+// 		if (!e->dirty) continue;
+// 		e->dirty = 0;
+// 		char* p = buf + e->pos;
+// 		switch (e->type) {
+// 		case xplmType_Int:        XPLMSetDatai(e->ref, *(int*)p); break;
+// 		case xplmType_Float:      XPLMSetDataf(e->ref, *(float*)p); break;
+// 		case xplmType_Double:     XPLMSetDatad(e->ref, *(double*)p); break;
+// 		case xplmType_IntArray:   XPLMSetDatavi(e->ref, (int*)p, e->offset, e->count); break;
+// 		case xplmType_FloatArray: XPLMSetDatavf(e->ref, (float*)p, e->offset, e->count); break;
+// 		case xplmType_Data:       XPLMSetDatab(e->ref, p, e->offset, e->count); break;
+// 		}
+// 	}
+// }
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Slot identifies a value in a Batch.
+type Slot int
+
+type batchSlot struct {
+	name     string
+	ref      DataRef
+	dataType DataType
+	offset   int // First array element read.
+	count    int // Number of elements, 1 for scalars.
+	word     int // Position of the value in the buffer.
+	writable bool
+	changed  bool
+}
+
+// Batch reads and writes a fixed set of datarefs in a single cgo call. Once
+// the datarefs are added, Read copies all of their values into a Go-owned
+// buffer, and Write pushes the values changed with the Set methods back to
+// X-Plane, instead of paying for one cgo call and one cache lookup per value.
+//
+//	b := dref.NewBatch()
+//	lat, _ := b.Add("sim/flightmodel/position/latitude", dref.TypeDouble)
+//	n1, _ := b.AddArray("sim/flightmodel/engine/ENGN_N1_", dref.TypeFloatArray, 0, 8)
+//
+//	// In a flight loop:
+//	b.Read()
+//	draw(b.Double(lat), b.FloatArray(n1))
+//
+// A Batch is meant to be used from the simulator thread and is not safe for
+// concurrent use. Call Destroy when it is no longer needed.
+type Batch struct {
+	slots []batchSlot
+	buf   []uint64 // 8-byte words, so every value is aligned for doubles.
+	desc  *C.batch_entry
+	stale bool // Slots were added since the descriptor was built.
+}
+
+// NewBatch creates an empty batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Add appends a scalar dataref read as TypeInt, TypeFloat or TypeDouble.
+// It returns ErrTypeMismatch if the dataref does not support that type.
+func (b *Batch) Add(name string, dataType DataType) (Slot, error) {
+	switch dataType {
+	case TypeInt, TypeFloat, TypeDouble:
+	default:
+		return 0, fmt.Errorf("dataref '%s': %s is not a scalar type: %w", name, dataType, ErrTypeMismatch)
+	}
+	return b.add(name, dataType, 0, 1)
+}
+
+// AddArray appends count elements of an array dataref, starting at offset,
+// read as TypeIntArray, TypeFloatArray or TypeData.
+func (b *Batch) AddArray(name string, dataType DataType, offset, count int) (Slot, error) {
+	switch dataType {
+	case TypeIntArray, TypeFloatArray, TypeData:
+	default:
+		return 0, fmt.Errorf("dataref '%s': %s is not an array type: %w", name, dataType, ErrTypeMismatch)
+	}
+	if offset < 0 || count <= 0 {
+		return 0, fmt.Errorf("dataref '%s': invalid range of %d elements at %d", name, count, offset)
+	}
+	return b.add(name, dataType, offset, count)
+}
+
+func (b *Batch) add(name string, dataType DataType, offset, count int) (Slot, error) {
+	ref, writable, err := findTyped(name, dataType)
+	if err != nil {
+		return 0, err
+	}
+	size := count * 4
+	switch dataType {
+	case TypeDouble:
+		size = 8
+	case TypeData:
+		size = count
+	}
+	b.slots = append(b.slots, batchSlot{
+		name:     name,
+		ref:      ref,
+		dataType: dataType,
+		offset:   offset,
+		count:    count,
+		word:     len(b.buf),
+		writable: writable,
+	})
+	b.buf = append(b.buf, make([]uint64, (size+7)/8)...)
+	b.stale = true
+	return Slot(len(b.slots) - 1), nil
+}
+
+// Len returns the number of datarefs in the batch.
+func (b *Batch) Len() int {
+	return len(b.slots)
+}
+
+// Read fetches the current value of every dataref in the batch.
+func (b *Batch) Read() {
+	if len(b.slots) == 0 {
+		return
+	}
+	b.compile()
+	C.batch_read(b.desc, C.int(len(b.slots)), (*C.char)(unsafe.Pointer(&b.buf[0])))
+}
+
+// Write sends the values changed with the Set methods since the last Write.
+func (b *Batch) Write() {
+	if len(b.slots) == 0 {
+		return
+	}
+	b.compile()
+	entries := unsafe.Slice(b.desc, len(b.slots))
+	for i := range b.slots {
+		if b.slots[i].changed {
+			entries[i].dirty = 1
+			b.slots[i].changed = false
+		}
+	}
+	C.batch_write(b.desc, C.int(len(b.slots)), (*C.char)(unsafe.Pointer(&b.buf[0])))
+}
+
+// Destroy releases the C descriptor. The batch must not be used afterwards.
+func (b *Batch) Destroy() {
+	if b.desc != nil {
+		C.free(unsafe.Pointer(b.desc))
+		b.desc = nil
+	}
+	b.slots = nil
+	b.buf = nil
+}
+
+// compile builds the C-side descriptor of the batch.
+func (b *Batch) compile() {
+	if !b.stale {
+		return
+	}
+	if b.desc != nil {
+		C.free(unsafe.Pointer(b.desc))
+	}
+	b.desc = (*C.batch_entry)(C.malloc(C.size_t(len(b.slots)) * C.size_t(unsafe.Sizeof(C.batch_entry{}))))
+	entries := unsafe.Slice(b.desc, len(b.slots))
+	for i, s := range b.slots {
+		entries[i] = C.batch_entry{
+			ref:    C.XPLMDataRef(s.ref),
+			_type:  C.int(s.dataType),
+			offset: C.int(s.offset),
+			count:  C.int(s.count),
+			pos:    C.size_t(s.word * 8),
+		}
+	}
+	b.stale = false
+}
+
+func (b *Batch) ptr(s Slot) unsafe.Pointer {
+	return unsafe.Pointer(&b.buf[b.slots[s].word])
+}
+
+// Int returns the value of a scalar slot as of the last Read, converted to int.
+func (b *Batch) Int(s Slot) int {
+	switch b.slots[s].dataType {
+	case TypeInt:
+		return int(*(*int32)(b.ptr(s)))
+	case TypeFloat:
+		return int(*(*float32)(b.ptr(s)))
+	case TypeDouble:
+		return int(*(*float64)(b.ptr(s)))
+	}
+	return 0
+}
+
+// Float returns the value of a scalar slot as of the last Read, converted to float32.
+func (b *Batch) Float(s Slot) float32 {
+	switch b.slots[s].dataType {
+	case TypeInt:
+		return float32(*(*int32)(b.ptr(s)))
+	case TypeFloat:
+		return *(*float32)(b.ptr(s))
+	case TypeDouble:
+		return float32(*(*float64)(b.ptr(s)))
+	}
+	return 0
+}
+
+// Double returns the value of a scalar slot as of the last Read, converted to float64.
+func (b *Batch) Double(s Slot) float64 {
+	switch b.slots[s].dataType {
+	case TypeInt:
+		return float64(*(*int32)(b.ptr(s)))
+	case TypeFloat:
+		return float64(*(*float32)(b.ptr(s)))
+	case TypeDouble:
+		return *(*float64)(b.ptr(s))
+	}
+	return 0
+}
+
+// IntArray returns the elements of an int array slot as of the last Read, or
+// nil if the slot holds another type. The slice aliases the batch buffer and
+// is overwritten by the next Read.
+func (b *Batch) IntArray(s Slot) []int32 {
+	if b.slots[s].dataType != TypeIntArray {
+		return nil
+	}
+	return unsafe.Slice((*int32)(b.ptr(s)), b.slots[s].count)
+}
+
+// FloatArray is like IntArray for float array slots.
+func (b *Batch) FloatArray(s Slot) []float32 {
+	if b.slots[s].dataType != TypeFloatArray {
+		return nil
+	}
+	return unsafe.Slice((*float32)(b.ptr(s)), b.slots[s].count)
+}
+
+// Bytes is like IntArray for byte data slots.
+func (b *Batch) Bytes(s Slot) []byte {
+	if b.slots[s].dataType != TypeData {
+		return nil
+	}
+	return unsafe.Slice((*byte)(b.ptr(s)), b.slots[s].count)
+}
+
+// SetInt stores a new value for a scalar slot, converted to the slot's type.
+// It is sent to X-Plane by the next Write.
+func (b *Batch) SetInt(s Slot, value int) error {
+	return b.setScalar(s, float64(value))
+}
+
+// SetFloat is like SetInt for float32 values.
+func (b *Batch) SetFloat(s Slot, value float32) error {
+	return b.setScalar(s, float64(value))
+}
+
+// SetDouble is like SetInt for float64 values.
+func (b *Batch) SetDouble(s Slot, value float64) error {
+	return b.setScalar(s, value)
+}
+
+func (b *Batch) setScalar(s Slot, value float64) error {
+	switch b.slots[s].dataType {
+	case TypeInt, TypeFloat, TypeDouble:
+	default:
+		return fmt.Errorf("dataref '%s' is %s, not a scalar: %w", b.slots[s].name, b.slots[s].dataType, ErrTypeMismatch)
+	}
+	if err := b.markChanged(s); err != nil {
+		return err
+	}
+	switch b.slots[s].dataType {
+	case TypeInt:
+		*(*int32)(b.ptr(s)) = int32(value)
+	case TypeFloat:
+		*(*float32)(b.ptr(s)) = float32(value)
+	case TypeDouble:
+		*(*float64)(b.ptr(s)) = value
+	}
+	return nil
+}
+
+// SetIntArray copies values into an int array slot; extra values are ignored.
+// They are sent to X-Plane by the next Write.
+func (b *Batch) SetIntArray(s Slot, values []int32) error {
+	return setBatchArray(b, s, TypeIntArray, b.IntArray(s), values)
+}
+
+// SetFloatArray is like SetIntArray for float array slots.
+func (b *Batch) SetFloatArray(s Slot, values []float32) error {
+	return setBatchArray(b, s, TypeFloatArray, b.FloatArray(s), values)
+}
+
+// SetBytes is like SetIntArray for byte data slots.
+func (b *Batch) SetBytes(s Slot, values []byte) error {
+	return setBatchArray(b, s, TypeData, b.Bytes(s), values)
+}
+
+func setBatchArray[E Element](b *Batch, s Slot, want DataType, dst, values []E) error {
+	if b.slots[s].dataType != want {
+		return fmt.Errorf("dataref '%s' is %s, not %s: %w", b.slots[s].name, b.slots[s].dataType, want, ErrTypeMismatch)
+	}
+	if err := b.markChanged(s); err != nil {
+		return err
+	}
+	copy(dst, values)
+	return nil
+}
+
+func (b *Batch) markChanged(s Slot) error {
+	if !b.slots[s].writable {
+		return fmt.Errorf("dataref '%s': %w", b.slots[s].name, ErrDataRefReadOnly)
+	}
+	b.slots[s].changed = true
+	return nil
+}
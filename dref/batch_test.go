@@ -0,0 +1,103 @@
+package dref
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+)
+
+// benchRefs is the number of floats read per benchmark iteration.
+const benchRefs = 32
+
+func TestBatchReadWrite(t *testing.T) {
+	names := addFloats(t, "batch", 3)
+	xplmfake.AddDataRef("xplane-go/test/batch_array", xplmfake.TypeFloatArray, true)
+	for i, name := range names {
+		ref, err := FindDataRef(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetFloat(ref, float32(i+1))
+	}
+
+	batch := NewBatch()
+	defer batch.Destroy()
+	slots := make([]Slot, len(names))
+	for i, name := range names {
+		var err error
+		if slots[i], err = batch.Add(name, TypeFloat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	array, err := batch.AddArray("xplane-go/test/batch_array", TypeFloatArray, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch.Read()
+	for i, s := range slots {
+		if got := batch.Float(s); got != float32(i+1) {
+			t.Errorf("Float(%s) = %v, want %v", names[i], got, i+1)
+		}
+	}
+
+	if err := batch.SetFloat(slots[1], 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.SetFloatArray(array, []float32{7, 8, 9}); err != nil {
+		t.Fatal(err)
+	}
+	batch.Write()
+	ref, _ := FindDataRef(names[1])
+	if got := GetFloat(ref); got != 42 {
+		t.Errorf("after Write, %s = %v, want 42", names[1], got)
+	}
+	ref, _ = FindDataRef("xplane-go/test/batch_array")
+	values := make([]float32, 6)
+	GetFloatArray(ref, values, 0)
+	if want := []float32{0, 0, 7, 8, 9, 0}; fmt.Sprint(values) != fmt.Sprint(want) {
+		t.Errorf("after Write, array = %v, want %v", values, want)
+	}
+}
+
+func BenchmarkBatchRead(b *testing.B) {
+	names := addFloats(b, "bench", benchRefs)
+	batch := NewBatch()
+	defer batch.Destroy()
+	slots := make([]Slot, len(names))
+	for i, name := range names {
+		var err error
+		if slots[i], err = batch.Add(name, TypeFloat); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var sum float32
+	for b.Loop() {
+		batch.Read()
+		for _, s := range slots {
+			sum += batch.Float(s)
+		}
+	}
+	_ = sum
+}
+
+func BenchmarkCacheGetFloat(b *testing.B) {
+	names := addFloats(b, "bench", benchRefs)
+	cache := NewDataRefCache()
+	for _, name := range names {
+		if err := cache.Register(name); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var sum float32
+	for b.Loop() {
+		for _, name := range names {
+			v, _ := cache.GetFloat(name)
+			sum += v
+		}
+	}
+	_ = sum
+}
@@ -0,0 +1,250 @@
+package dref
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// fieldKind identifies how a bound struct field is transferred.
+type fieldKind int
+
+const (
+	kindInt fieldKind = iota
+	kindInt32
+	kindBool
+	kindFloat
+	kindDouble
+	kindDoubleFromFloat
+	kindIntElement
+	kindFloatElement
+	kindIntArray
+	kindFloatArray
+	kindByteArray
+	kindString
+)
+
+type boundField struct {
+	field     string
+	name      string
+	ref       DataRef
+	kind      fieldKind
+	offset    uintptr // Byte offset of the field inside the struct.
+	index     int     // Array offset for indexed names and array fields.
+	length    int     // Element count for array fields.
+	writable  bool
+	skipWrite bool
+}
+
+// Binding maps the tagged fields of a struct type onto datarefs so a whole
+// snapshot can be read or written in one call.
+//
+// Fields are bound with an `xp` tag holding the dataref name:
+//
+//	type Position struct {
+//		Lat  float64    `xp:"sim/flightmodel/position/latitude"`
+//		N1   [8]float32 `xp:"sim/flightmodel/engine/ENGN_N1_"`
+//		Gear float32    `xp:"sim/flightmodel2/gear/deploy_ratio[0]"`
+//		Tail string     `xp:"sim/aircraft/view/acf_tailnum,readonly"`
+//	}
+//
+// Supported field types are int, int32 and bool (int datarefs), float32,
+// float64, fixed-size arrays of int32, float32 and byte, and string (byte
+// datarefs). A "[n]" suffix on the name reads element n of an array dataref
+// into a scalar field, or starts an array field at element n. The "readonly"
+// option excludes the field from Write.
+type Binding[T any] struct {
+	fields []boundField
+}
+
+// Bind resolves and type-checks every tagged field of T once.
+// T must be a struct type.
+func Bind[T any]() (*Binding[T], error) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("dref: cannot bind %s: not a struct", typ)
+	}
+
+	b := &Binding[T]{}
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag, ok := sf.Tag.Lookup("xp")
+		if !ok || tag == "" || tag == "-" {
+			continue
+		}
+		f, err := bindField(sf, tag)
+		if err != nil {
+			return nil, fmt.Errorf("dref: cannot bind %s.%s: %w", typ.Name(), sf.Name, err)
+		}
+		b.fields = append(b.fields, f)
+	}
+	return b, nil
+}
+
+func bindField(sf reflect.StructField, tag string) (boundField, error) {
+	f := boundField{field: sf.Name, offset: sf.Offset}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	for _, opt := range strings.Split(opts, ",") {
+		switch opt {
+		case "":
+		case "readonly":
+			f.skipWrite = true
+		default:
+			return f, fmt.Errorf("unknown tag option %q", opt)
+		}
+	}
+
+	indexed := false
+	if open := strings.LastIndexByte(name, '['); open > 0 && strings.HasSuffix(name, "]") {
+		n, err := strconv.Atoi(name[open+1 : len(name)-1])
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("invalid array index in %q", name)
+		}
+		f.index = n
+		name = name[:open]
+		indexed = true
+	}
+	f.name = name
+
+	ref, err := FindDataRef(name)
+	if err != nil {
+		return f, fmt.Errorf("dataref '%s': %w", name, err)
+	}
+	f.ref = ref
+	f.writable = CanWriteDataRef(ref)
+	have := GetDataRefTypes(ref)
+
+	var want DataType
+	switch t := sf.Type; {
+	case t.Kind() == reflect.Int && !indexed:
+		f.kind, want = kindInt, TypeInt
+	case t.Kind() == reflect.Int32 && !indexed:
+		f.kind, want = kindInt32, TypeInt
+	case t.Kind() == reflect.Int32 && indexed:
+		f.kind, want = kindIntElement, TypeIntArray
+	case t.Kind() == reflect.Bool && !indexed:
+		f.kind, want = kindBool, TypeInt
+	case t.Kind() == reflect.Float32 && indexed:
+		f.kind, want = kindFloatElement, TypeFloatArray
+	case t.Kind() == reflect.Float32:
+		f.kind, want = kindFloat, TypeFloat
+	case t.Kind() == reflect.Float64 && !indexed:
+		f.kind, want = kindDouble, TypeDouble
+		if have&TypeDouble == 0 && have&TypeFloat != 0 {
+			// Widening a float dataref into a float64 field is lossless.
+			f.kind, want = kindDoubleFromFloat, TypeFloat
+		}
+	case t.Kind() == reflect.String && !indexed:
+		f.kind, want = kindString, TypeData
+	case t.Kind() == reflect.Array && t.Len() > 0:
+		f.length = t.Len()
+		switch t.Elem().Kind() {
+		case reflect.Int32:
+			f.kind, want = kindIntArray, TypeIntArray
+		case reflect.Float32:
+			f.kind, want = kindFloatArray, TypeFloatArray
+		case reflect.Uint8:
+			f.kind, want = kindByteArray, TypeData
+		default:
+			return f, fmt.Errorf("unsupported array element type %s", t.Elem())
+		}
+	case indexed:
+		return f, fmt.Errorf("unsupported field type %s for an array element", t)
+	default:
+		return f, fmt.Errorf("unsupported field type %s", t)
+	}
+
+	if have&want == 0 {
+		return f, fmt.Errorf("dataref '%s' is %s, not %s: %w", name, have, want, ErrTypeMismatch)
+	}
+	return f, nil
+}
+
+// Read fills every bound field of dst from the simulator.
+func (b *Binding[T]) Read(dst *T) {
+	base := unsafe.Pointer(dst)
+	for i := range b.fields {
+		f := &b.fields[i]
+		p := unsafe.Add(base, f.offset)
+		switch f.kind {
+		case kindInt:
+			*(*int)(p) = GetInt(f.ref)
+		case kindInt32:
+			*(*int32)(p) = int32(GetInt(f.ref))
+		case kindBool:
+			*(*bool)(p) = GetInt(f.ref) != 0
+		case kindFloat:
+			*(*float32)(p) = GetFloat(f.ref)
+		case kindDouble:
+			*(*float64)(p) = GetDouble(f.ref)
+		case kindDoubleFromFloat:
+			*(*float64)(p) = float64(GetFloat(f.ref))
+		case kindIntElement:
+			GetIntArray(f.ref, unsafe.Slice((*int32)(p), 1), f.index)
+		case kindFloatElement:
+			GetFloatArray(f.ref, unsafe.Slice((*float32)(p), 1), f.index)
+		case kindIntArray:
+			GetIntArray(f.ref, unsafe.Slice((*int32)(p), f.length), f.index)
+		case kindFloatArray:
+			GetFloatArray(f.ref, unsafe.Slice((*float32)(p), f.length), f.index)
+		case kindByteArray:
+			GetBytesAt(f.ref, unsafe.Slice((*byte)(p), f.length), f.index)
+		case kindString:
+			*(*string)(p) = GetString(f.ref)
+		}
+	}
+}
+
+// Write sends every bound field of src to the simulator, except fields tagged
+// "readonly". It returns ErrDataRefReadOnly without writing anything if one of
+// those fields is bound to a dataref that cannot be written.
+func (b *Binding[T]) Write(src *T) error {
+	for i := range b.fields {
+		if f := &b.fields[i]; !f.skipWrite && !f.writable {
+			return fmt.Errorf("field %s: dataref '%s': %w", f.field, f.name, ErrDataRefReadOnly)
+		}
+	}
+
+	base := unsafe.Pointer(src)
+	for i := range b.fields {
+		f := &b.fields[i]
+		if f.skipWrite {
+			continue
+		}
+		p := unsafe.Add(base, f.offset)
+		switch f.kind {
+		case kindInt:
+			SetInt(f.ref, *(*int)(p))
+		case kindInt32:
+			SetInt(f.ref, int(*(*int32)(p)))
+		case kindBool:
+			v := 0
+			if *(*bool)(p) {
+				v = 1
+			}
+			SetInt(f.ref, v)
+		case kindFloat:
+			SetFloat(f.ref, *(*float32)(p))
+		case kindDouble:
+			SetDouble(f.ref, *(*float64)(p))
+		case kindDoubleFromFloat:
+			SetFloat(f.ref, float32(*(*float64)(p)))
+		case kindIntElement:
+			SetIntArray(f.ref, unsafe.Slice((*int32)(p), 1), f.index)
+		case kindFloatElement:
+			SetFloatArray(f.ref, unsafe.Slice((*float32)(p), 1), f.index)
+		case kindIntArray:
+			SetIntArray(f.ref, unsafe.Slice((*int32)(p), f.length), f.index)
+		case kindFloatArray:
+			SetFloatArray(f.ref, unsafe.Slice((*float32)(p), f.length), f.index)
+		case kindByteArray:
+			SetBytesAt(f.ref, unsafe.Slice((*byte)(p), f.length), f.index)
+		case kindString:
+			SetString(f.ref, *(*string)(p))
+		}
+	}
+	return nil
+}
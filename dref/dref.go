@@ -5,8 +5,10 @@ package dref
 // #include "XPLMDataAccess.h"
 import "C"
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"unsafe"
@@ -14,21 +16,81 @@ import (
 
 type DataRef unsafe.Pointer
 
+// stringBuffers recycles the scratch buffers used by GetString and SetString.
+var stringBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+// DataType is a bitmask describing the types a dataref can be accessed as.
+// A single dataref may support several types at once (e.g. float and double).
+type DataType int
+
+const (
+	TypeUnknown    DataType = C.xplmType_Unknown
+	TypeInt        DataType = C.xplmType_Int
+	TypeFloat      DataType = C.xplmType_Float
+	TypeDouble     DataType = C.xplmType_Double
+	TypeFloatArray DataType = C.xplmType_FloatArray
+	TypeIntArray   DataType = C.xplmType_IntArray
+	TypeData       DataType = C.xplmType_Data
+)
+
 var (
 	ErrDataRefNotFound  = errors.New("dataref not found")
 	ErrRefNotRegistered = errors.New("dataref not registered in cache")
+	ErrTypeMismatch     = errors.New("dataref type mismatch")
+	ErrDataRefReadOnly  = errors.New("dataref is read-only")
+	ErrRefPending       = errors.New("dataref not yet published")
 )
 
+// String returns the SDK-style names of the types set in the mask, joined by '|'.
+func (t DataType) String() string {
+	if t == TypeUnknown {
+		return "unknown"
+	}
+	names := []struct {
+		t    DataType
+		name string
+	}{
+		{TypeInt, "int"},
+		{TypeFloat, "float"},
+		{TypeDouble, "double"},
+		{TypeFloatArray, "float[]"},
+		{TypeIntArray, "int[]"},
+		{TypeData, "data"},
+	}
+	var parts []string
+	for _, n := range names {
+		if t&n.t != 0 {
+			parts = append(parts, n.name)
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
 // DataRefCache holds pre-found DataRef handles for fast, repeated access.
 type DataRefCache struct {
-	refs  map[string]DataRef
-	mutex sync.RWMutex
+	refs    map[string]*cachedRef
+	pending map[string][]ReadyFunc
+	mutex   sync.RWMutex
+}
+
+// cachedRef is a registered dataref together with the properties queried at
+// registration time.
+type cachedRef struct {
+	ref      DataRef
+	types    DataType
+	writable bool
 }
 
 // NewDataRefCache creates a new, empty cache for datarefs.
 func NewDataRefCache() *DataRefCache {
 	return &DataRefCache{
-		refs: make(map[string]DataRef),
+		refs:    make(map[string]*cachedRef),
+		pending: make(map[string][]ReadyFunc),
 	}
 }
 
@@ -36,31 +98,94 @@ func NewDataRefCache() *DataRefCache {
 // This should be done during plugin initialization.
 func (c *DataRefCache) Register(name string) error {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
 
 	// Avoid re-finding if already present
 	if _, exists := c.refs[name]; exists {
+		c.mutex.Unlock()
 		return nil
 	}
 
 	ref, err := FindDataRef(name)
 	if err != nil {
+		c.mutex.Unlock()
 		return fmt.Errorf("could not register dataref '%s': %w", name, err)
 	}
 
-	c.refs[name] = ref
+	callbacks := c.store(name, ref)
+	c.mutex.Unlock()
+	notifyReady(name, callbacks)
 	return nil
 }
 
+// store records a found dataref and its properties, and removes it from the
+// pending names. It returns the ready callbacks queued for it, which the
+// caller calls once the lock is released. The caller must hold the write lock.
+func (c *DataRefCache) store(name string, ref DataRef) []ReadyFunc {
+	c.refs[name] = &cachedRef{
+		ref:      ref,
+		types:    GetDataRefTypes(ref),
+		writable: CanWriteDataRef(ref),
+	}
+	callbacks := c.pending[name]
+	delete(c.pending, name)
+	return callbacks
+}
+
+// missing builds the error returned for a name that is not in the cache.
+// The caller must hold the lock.
+func (c *DataRefCache) missing(name string) error {
+	if _, ok := c.pending[name]; ok {
+		return fmt.Errorf("dataref '%s': %w", name, ErrRefPending)
+	}
+	return fmt.Errorf("dataref '%s': %w", name, ErrRefNotRegistered)
+}
+
 // getRef is an internal helper to safely get a registered dataref handle.
 func (c *DataRefCache) getRef(name string) (DataRef, error) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	ref, ok := c.refs[name]
+	entry, ok := c.refs[name]
+	if !ok {
+		return nil, c.missing(name)
+	}
+	return entry.ref, nil
+}
+
+// getWritableRef is like getRef but fails with ErrDataRefReadOnly if X-Plane
+// reported the dataref as read-only when it was registered.
+func (c *DataRefCache) getWritableRef(name string) (DataRef, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, ok := c.refs[name]
 	if !ok {
-		return nil, fmt.Errorf("dataref '%s': %w", name, ErrRefNotRegistered)
+		return nil, c.missing(name)
 	}
-	return ref, nil
+	if !entry.writable {
+		return nil, fmt.Errorf("dataref '%s': %w", name, ErrDataRefReadOnly)
+	}
+	return entry.ref, nil
+}
+
+// Type returns the types a pre-registered dataref can be accessed as.
+func (c *DataRefCache) Type(name string) (DataType, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, ok := c.refs[name]
+	if !ok {
+		return TypeUnknown, c.missing(name)
+	}
+	return entry.types, nil
+}
+
+// Writable reports whether a pre-registered dataref can be written to.
+func (c *DataRefCache) Writable(name string) (bool, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, ok := c.refs[name]
+	if !ok {
+		return false, c.missing(name)
+	}
+	return entry.writable, nil
 }
 
 // GetInt retrieves the value of a pre-registered integer dataref.
@@ -99,6 +224,17 @@ func (c *DataRefCache) GetString(name string) (string, error) {
 	return GetString(ref), nil
 }
 
+// AppendString appends the value of a pre-registered string dataref to dst.
+// Like the package-level AppendString, it only allocates when dst is too
+// small.
+func (c *DataRefCache) AppendString(name string, dst []byte) ([]byte, error) {
+	ref, err := c.getRef(name)
+	if err != nil {
+		return dst, err
+	}
+	return AppendString(dst, ref), nil
+}
+
 // GetBytes retrieves the value of a pre-registered byte array dataref.
 func (c *DataRefCache) GetBytes(name string, buffer []byte) (int, error) {
 	ref, err := c.getRef(name)
@@ -108,6 +244,112 @@ func (c *DataRefCache) GetBytes(name string, buffer []byte) (int, error) {
 	return GetBytes(ref, buffer), nil
 }
 
+// SetInt writes the value of a pre-registered integer dataref.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (c *DataRefCache) SetInt(name string, value int) error {
+	ref, err := c.getWritableRef(name)
+	if err != nil {
+		return err
+	}
+	SetInt(ref, value)
+	return nil
+}
+
+// SetFloat writes the value of a pre-registered float dataref.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (c *DataRefCache) SetFloat(name string, value float32) error {
+	ref, err := c.getWritableRef(name)
+	if err != nil {
+		return err
+	}
+	SetFloat(ref, value)
+	return nil
+}
+
+// SetDouble writes the value of a pre-registered double dataref.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (c *DataRefCache) SetDouble(name string, value float64) error {
+	ref, err := c.getWritableRef(name)
+	if err != nil {
+		return err
+	}
+	SetDouble(ref, value)
+	return nil
+}
+
+// SetBytes writes data to a pre-registered byte array dataref.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (c *DataRefCache) SetBytes(name string, data []byte) error {
+	ref, err := c.getWritableRef(name)
+	if err != nil {
+		return err
+	}
+	SetBytes(ref, data)
+	return nil
+}
+
+// SetString writes a null-terminated string to a pre-registered byte array dataref.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (c *DataRefCache) SetString(name string, value string) error {
+	ref, err := c.getWritableRef(name)
+	if err != nil {
+		return err
+	}
+	SetString(ref, value)
+	return nil
+}
+
+// GetIntArray reads elements of a pre-registered integer array dataref into buffer,
+// starting at offset. It returns the number of elements read.
+func (c *DataRefCache) GetIntArray(name string, buffer []int32, offset int) (int, error) {
+	ref, err := c.getRef(name)
+	if err != nil {
+		return 0, err
+	}
+	return GetIntArray(ref, buffer, offset), nil
+}
+
+// GetFloatArray reads elements of a pre-registered float array dataref into buffer,
+// starting at offset. It returns the number of elements read.
+func (c *DataRefCache) GetFloatArray(name string, buffer []float32, offset int) (int, error) {
+	ref, err := c.getRef(name)
+	if err != nil {
+		return 0, err
+	}
+	return GetFloatArray(ref, buffer, offset), nil
+}
+
+// SetIntArray writes values to a pre-registered integer array dataref, starting at offset.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (c *DataRefCache) SetIntArray(name string, values []int32, offset int) error {
+	ref, err := c.getWritableRef(name)
+	if err != nil {
+		return err
+	}
+	SetIntArray(ref, values, offset)
+	return nil
+}
+
+// SetFloatArray writes values to a pre-registered float array dataref, starting at offset.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (c *DataRefCache) SetFloatArray(name string, values []float32, offset int) error {
+	ref, err := c.getWritableRef(name)
+	if err != nil {
+		return err
+	}
+	SetFloatArray(ref, values, offset)
+	return nil
+}
+
+// ArrayLen returns the number of elements of a pre-registered array or byte dataref.
+func (c *DataRefCache) ArrayLen(name string) (int, error) {
+	ref, err := c.getRef(name)
+	if err != nil {
+		return 0, err
+	}
+	return ArrayLen(ref), nil
+}
+
 // FindDataRef looks up a dataref by its string identifier.
 // Returns an error if the dataref cannot be found.
 func FindDataRef(name string) (DataRef, error) {
@@ -145,11 +387,42 @@ func GetDouble(ref DataRef) float64 {
 	return float64(C.XPLMGetDatad(C.XPLMDataRef(ref)))
 }
 
-// GetString reads a string dataref. It reads up to 256 bytes and trims null characters.
+// GetString reads a string dataref. The size of the dataref is queried first,
+// so long values such as aircraft paths are never truncated. The string stops
+// at the first null character.
 func GetString(ref DataRef) string {
-	buffer := make([]byte, 256)
-	count := GetBytes(ref, buffer)
-	return strings.TrimRight(string(buffer[:count]), "\x00")
+	bufPtr := stringBuffers.Get().(*[]byte)
+	buf := AppendString((*bufPtr)[:0], ref)
+	s := string(buf)
+	*bufPtr = buf[:0]
+	stringBuffers.Put(bufPtr)
+	return s
+}
+
+// AppendString appends the value of a string dataref to dst and returns the
+// extended slice. It only allocates when dst is too small, so reusing the
+// same buffer across frames keeps flight loops allocation-free.
+func AppendString(dst []byte, ref DataRef) []byte {
+	size := BytesLen(ref)
+	if size <= 0 {
+		return dst
+	}
+	start := len(dst)
+	dst = slices.Grow(dst, size)[:start+size]
+	value := dst[start : start+GetBytesAt(ref, dst[start:], 0)]
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return dst[:start+len(value)]
+}
+
+// SetString writes s to a string dataref, followed by a null terminator.
+func SetString(ref DataRef, s string) {
+	bufPtr := stringBuffers.Get().(*[]byte)
+	buf := append(append((*bufPtr)[:0], s...), 0)
+	SetBytes(ref, buf)
+	*bufPtr = buf[:0]
+	stringBuffers.Put(bufPtr)
 }
 
 // SetDouble sets the value of a double-precision float dataref.
@@ -160,26 +433,137 @@ func SetDouble(ref DataRef, value float64) {
 // GetBytes reads a byte array dataref into the provided slice.
 // It returns the number of bytes actually read.
 func GetBytes(ref DataRef, buffer []byte) int {
+	return GetBytesAt(ref, buffer, 0)
+}
+
+// SetBytes writes a byte slice to a dataref.
+func SetBytes(ref DataRef, data []byte) {
+	SetBytesAt(ref, data, 0)
+}
+
+// BytesLen returns the size in bytes of a byte array dataref.
+func BytesLen(ref DataRef) int {
+	return int(C.XPLMGetDatab(C.XPLMDataRef(ref), nil, 0, 0))
+}
+
+// GetBytesAt reads a byte array dataref into the provided slice, starting at
+// byte offset. It returns the number of bytes actually read.
+func GetBytesAt(ref DataRef, buffer []byte, offset int) int {
 	if len(buffer) == 0 {
 		return 0
 	}
 	return int(C.XPLMGetDatab(
 		C.XPLMDataRef(ref),
 		unsafe.Pointer(&buffer[0]),
-		0,
+		C.int(offset),
 		C.int(len(buffer)),
 	))
 }
 
-// SetBytes writes a byte slice to a dataref.
-func SetBytes(ref DataRef, data []byte) {
+// SetBytesAt writes a byte slice to a dataref, starting at byte offset.
+func SetBytesAt(ref DataRef, data []byte, offset int) {
 	if len(data) == 0 {
 		return
 	}
 	C.XPLMSetDatab(
 		C.XPLMDataRef(ref),
 		unsafe.Pointer(&data[0]),
-		0,
+		C.int(offset),
 		C.int(len(data)),
 	)
 }
+
+// GetDataRefTypes returns the types a dataref can be accessed as.
+func GetDataRefTypes(ref DataRef) DataType {
+	return DataType(C.XPLMGetDataRefTypes(C.XPLMDataRef(ref)))
+}
+
+// IsDataRefGood reports whether a dataref handle is still valid, i.e. its
+// owning plugin has not been unloaded.
+func IsDataRefGood(ref DataRef) bool {
+	return C.XPLMIsDataRefGood(C.XPLMDataRef(ref)) != 0
+}
+
+// CanWriteDataRef reports whether a dataref can be written to.
+func CanWriteDataRef(ref DataRef) bool {
+	return C.XPLMCanWriteDataRef(C.XPLMDataRef(ref)) != 0
+}
+
+// GetIntArray reads elements of an integer array dataref into the provided slice,
+// starting at offset. It returns the number of elements actually read.
+func GetIntArray(ref DataRef, buffer []int32, offset int) int {
+	if len(buffer) == 0 {
+		return 0
+	}
+	return int(C.XPLMGetDatavi(
+		C.XPLMDataRef(ref),
+		(*C.int)(unsafe.Pointer(&buffer[0])),
+		C.int(offset),
+		C.int(len(buffer)),
+	))
+}
+
+// SetIntArray writes values to an integer array dataref, starting at offset.
+func SetIntArray(ref DataRef, values []int32, offset int) {
+	if len(values) == 0 {
+		return
+	}
+	C.XPLMSetDatavi(
+		C.XPLMDataRef(ref),
+		(*C.int)(unsafe.Pointer(&values[0])),
+		C.int(offset),
+		C.int(len(values)),
+	)
+}
+
+// GetFloatArray reads elements of a float array dataref into the provided slice,
+// starting at offset. It returns the number of elements actually read.
+func GetFloatArray(ref DataRef, buffer []float32, offset int) int {
+	if len(buffer) == 0 {
+		return 0
+	}
+	return int(C.XPLMGetDatavf(
+		C.XPLMDataRef(ref),
+		(*C.float)(unsafe.Pointer(&buffer[0])),
+		C.int(offset),
+		C.int(len(buffer)),
+	))
+}
+
+// SetFloatArray writes values to a float array dataref, starting at offset.
+func SetFloatArray(ref DataRef, values []float32, offset int) {
+	if len(values) == 0 {
+		return
+	}
+	C.XPLMSetDatavf(
+		C.XPLMDataRef(ref),
+		(*C.float)(unsafe.Pointer(&values[0])),
+		C.int(offset),
+		C.int(len(values)),
+	)
+}
+
+// IntArrayLen returns the number of elements of an integer array dataref.
+func IntArrayLen(ref DataRef) int {
+	return int(C.XPLMGetDatavi(C.XPLMDataRef(ref), nil, 0, 0))
+}
+
+// FloatArrayLen returns the number of elements of a float array dataref.
+func FloatArrayLen(ref DataRef) int {
+	return int(C.XPLMGetDatavf(C.XPLMDataRef(ref), nil, 0, 0))
+}
+
+// ArrayLen returns the number of elements of an array dataref, or the number
+// of bytes of a byte dataref. It returns 0 for scalar datarefs.
+func ArrayLen(ref DataRef) int {
+	types := GetDataRefTypes(ref)
+	switch {
+	case types&TypeFloatArray != 0:
+		return FloatArrayLen(ref)
+	case types&TypeIntArray != 0:
+		return IntArrayLen(ref)
+	case types&TypeData != 0:
+		return BytesLen(ref)
+	}
+	return 0
+}
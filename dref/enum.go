@@ -0,0 +1,86 @@
+package dref
+
+// #cgo CFLAGS: -DXPLM400=1 -DXPLM410=1
+// #include <stdlib.h>
+// #include "XPLMDataAccess.h"
+import "C"
+
+import (
+	"iter"
+	"unsafe"
+
+	"github.com/akhenakh/xplane-go/plugin"
+)
+
+// DataRefInfo describes a dataref as reported by XPLMGetDataRefInfo.
+type DataRefInfo struct {
+	Name     string
+	Type     DataType
+	Writable bool
+	Owner    plugin.PluginID
+}
+
+// enumChunk is the number of handles fetched per XPLMGetDataRefsByIndex call.
+const enumChunk = 256
+
+// CountDataRefs returns the number of datarefs currently known to the simulator,
+// including those published by plugins. Requires X-Plane 12.
+func CountDataRefs() int {
+	return int(C.XPLMCountDataRefs())
+}
+
+// GetDataRefsByIndex fills refs with the handles of the datarefs starting at
+// index offset and returns how many were stored. Requires X-Plane 12.
+func GetDataRefsByIndex(offset int, refs []DataRef) int {
+	n := min(len(refs), CountDataRefs()-offset)
+	if offset < 0 || n <= 0 {
+		return 0
+	}
+	// X-Plane writes raw handles, so let it write into C memory.
+	buf := (*C.XPLMDataRef)(C.malloc(C.size_t(n) * C.size_t(unsafe.Sizeof(C.XPLMDataRef(nil)))))
+	defer C.free(unsafe.Pointer(buf))
+	C.XPLMGetDataRefsByIndex(C.int(offset), C.int(n), buf)
+	for i, ref := range unsafe.Slice(buf, n) {
+		refs[i] = DataRef(ref)
+	}
+	return n
+}
+
+// GetDataRefInfo returns the name, types, writability and owning plugin of a
+// dataref. Requires X-Plane 12.
+func GetDataRefInfo(ref DataRef) DataRefInfo {
+	info := C.XPLMDataRefInfo_t{
+		structSize: C.int(unsafe.Sizeof(C.XPLMDataRefInfo_t{})),
+	}
+	C.XPLMGetDataRefInfo(C.XPLMDataRef(ref), &info)
+	return DataRefInfo{
+		Name:     C.GoString(info.name),
+		Type:     DataType(info._type),
+		Writable: info.writable != 0,
+		Owner:    plugin.PluginID(info.owner),
+	}
+}
+
+// AllDataRefs iterates over every dataref in the simulator with its metadata.
+// Handles are fetched in chunks, so the iteration does not hold a full copy of
+// the dataref table in memory. Requires X-Plane 12.
+//
+//	for ref, info := range dref.AllDataRefs() {
+//		if info.Writable && strings.HasPrefix(info.Name, "sim/cockpit2/") { ... }
+//	}
+func AllDataRefs() iter.Seq2[DataRef, DataRefInfo] {
+	return func(yield func(DataRef, DataRefInfo) bool) {
+		refs := make([]DataRef, enumChunk)
+		for offset := 0; ; offset += enumChunk {
+			n := GetDataRefsByIndex(offset, refs)
+			if n == 0 {
+				return
+			}
+			for _, ref := range refs[:n] {
+				if !yield(ref, GetDataRefInfo(ref)) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package dref
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/akhenakh/xplane-go/plugin"
+)
+
+// overrideEntry is a dataref written through an OverrideManager together
+// with the value it had before the first write.
+type overrideEntry struct {
+	name     string
+	ref      DataRef
+	dataType DataType // Type the original value was saved as.
+	i        int
+	f        float32
+	d        float64
+	ints     []int32
+	floats   []float32
+	bytes    []byte
+}
+
+// OverrideManager writes datarefs on behalf of a plugin and remembers the
+// value each one had before it was first written, so that everything can be
+// put back when the plugin lets go. It is typically used for the
+// sim/operation/override/* datarefs:
+//
+//	overrides := dref.NewOverrideManager()
+//	overrides.Override("sim/operation/override/override_joystick")
+//
+// Every manager is restored automatically, most recent write first, when the
+// plugin is disabled or stopped.
+type OverrideManager struct {
+	entries []*overrideEntry // In order of first write.
+	mutex   sync.Mutex
+}
+
+var (
+	overrideManagers      []*OverrideManager
+	overrideManagersMutex sync.Mutex
+)
+
+func init() {
+	plugin.OnDisable(RestoreOverrides)
+	plugin.OnStop(RestoreOverrides)
+}
+
+// NewOverrideManager creates a manager that is restored when the plugin is
+// disabled or stopped. Call Close to restore and discard it earlier.
+func NewOverrideManager() *OverrideManager {
+	m := &OverrideManager{}
+	overrideManagersMutex.Lock()
+	overrideManagers = append(overrideManagers, m)
+	overrideManagersMutex.Unlock()
+	return m
+}
+
+// RestoreOverrides restores every OverrideManager, the most recently created
+// first. It is called automatically when the plugin is disabled or stopped.
+func RestoreOverrides() {
+	overrideManagersMutex.Lock()
+	managers := slices.Clone(overrideManagers)
+	overrideManagersMutex.Unlock()
+
+	for _, m := range slices.Backward(managers) {
+		m.RestoreAll()
+	}
+}
+
+// Override sets an override dataref such as
+// sim/operation/override/override_joystick to 1. For per-aircraft int array
+// overrides such as override_planepath, only the user aircraft (element 0) is
+// set.
+func (m *OverrideManager) Override(name string) error {
+	ref, err := FindDataRef(name)
+	if err == nil && GetDataRefTypes(ref)&(TypeInt|TypeIntArray) == TypeIntArray {
+		return m.SetIntArray(name, []int32{1}, 0)
+	}
+	return m.SetInt(name, 1)
+}
+
+// SetInt writes an int dataref, saving its original value on first use.
+func (m *OverrideManager) SetInt(name string, value int) error {
+	e, err := m.hold(name, TypeInt)
+	if err != nil {
+		return err
+	}
+	SetInt(e.ref, value)
+	return nil
+}
+
+// SetFloat writes a float dataref, saving its original value on first use.
+func (m *OverrideManager) SetFloat(name string, value float32) error {
+	e, err := m.hold(name, TypeFloat)
+	if err != nil {
+		return err
+	}
+	SetFloat(e.ref, value)
+	return nil
+}
+
+// SetDouble writes a double dataref, saving its original value on first use.
+func (m *OverrideManager) SetDouble(name string, value float64) error {
+	e, err := m.hold(name, TypeDouble)
+	if err != nil {
+		return err
+	}
+	SetDouble(e.ref, value)
+	return nil
+}
+
+// SetIntArray writes values into an int array dataref starting at offset,
+// saving the whole original array on first use.
+func (m *OverrideManager) SetIntArray(name string, values []int32, offset int) error {
+	e, err := m.hold(name, TypeIntArray)
+	if err != nil {
+		return err
+	}
+	SetIntArray(e.ref, values, offset)
+	return nil
+}
+
+// SetFloatArray writes values into a float array dataref starting at offset,
+// saving the whole original array on first use.
+func (m *OverrideManager) SetFloatArray(name string, values []float32, offset int) error {
+	e, err := m.hold(name, TypeFloatArray)
+	if err != nil {
+		return err
+	}
+	SetFloatArray(e.ref, values, offset)
+	return nil
+}
+
+// SetBytes writes a byte dataref, saving its original content on first use.
+func (m *OverrideManager) SetBytes(name string, data []byte) error {
+	e, err := m.hold(name, TypeData)
+	if err != nil {
+		return err
+	}
+	SetBytes(e.ref, data)
+	return nil
+}
+
+// Held returns the names of the datarefs currently overridden through the
+// manager, in the order they were first written.
+func (m *OverrideManager) Held() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	names := make([]string, len(m.entries))
+	for i, e := range m.entries {
+		names[i] = e.name
+	}
+	return names
+}
+
+// IsHeld reports whether name has been written through the manager and not
+// restored yet.
+func (m *OverrideManager) IsHeld(name string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.find(name) >= 0
+}
+
+// Restore puts back the original value of a single dataref. It does nothing
+// if the dataref is not held.
+func (m *OverrideManager) Restore(name string) {
+	m.mutex.Lock()
+	i := m.find(name)
+	if i < 0 {
+		m.mutex.Unlock()
+		return
+	}
+	e := m.entries[i]
+	m.entries = slices.Delete(m.entries, i, i+1)
+	m.mutex.Unlock()
+	e.restore()
+}
+
+// RestoreAll puts back the original value of every held dataref, most recent
+// first, so that overrides stacked on top of each other unwind correctly.
+func (m *OverrideManager) RestoreAll() {
+	m.mutex.Lock()
+	entries := m.entries
+	m.entries = nil
+	m.mutex.Unlock()
+
+	for _, e := range slices.Backward(entries) {
+		e.restore()
+	}
+}
+
+// Close restores every held dataref and detaches the manager from the
+// automatic restore on disable.
+func (m *OverrideManager) Close() {
+	m.RestoreAll()
+	overrideManagersMutex.Lock()
+	defer overrideManagersMutex.Unlock()
+	if i := slices.Index(overrideManagers, m); i >= 0 {
+		overrideManagers = slices.Delete(overrideManagers, i, i+1)
+	}
+}
+
+// hold returns the entry for name, looking the dataref up and saving its
+// current value the first time it is written.
+func (m *OverrideManager) hold(name string, dataType DataType) (*overrideEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if i := m.find(name); i >= 0 {
+		return m.entries[i], nil
+	}
+
+	ref, writable, err := findTyped(name, dataType)
+	if err != nil {
+		return nil, err
+	}
+	if !writable {
+		return nil, fmt.Errorf("dataref '%s': %w", name, ErrDataRefReadOnly)
+	}
+	e := &overrideEntry{name: name, ref: ref, dataType: dataType}
+	e.save()
+	m.entries = append(m.entries, e)
+	return e, nil
+}
+
+func (m *OverrideManager) find(name string) int {
+	return slices.IndexFunc(m.entries, func(e *overrideEntry) bool { return e.name == name })
+}
+
+func (e *overrideEntry) save() {
+	switch e.dataType {
+	case TypeInt:
+		e.i = GetInt(e.ref)
+	case TypeFloat:
+		e.f = GetFloat(e.ref)
+	case TypeDouble:
+		e.d = GetDouble(e.ref)
+	case TypeIntArray:
+		e.ints = make([]int32, IntArrayLen(e.ref))
+		GetIntArray(e.ref, e.ints, 0)
+	case TypeFloatArray:
+		e.floats = make([]float32, FloatArrayLen(e.ref))
+		GetFloatArray(e.ref, e.floats, 0)
+	case TypeData:
+		e.bytes = make([]byte, BytesLen(e.ref))
+		GetBytes(e.ref, e.bytes)
+	}
+}
+
+func (e *overrideEntry) restore() {
+	switch e.dataType {
+	case TypeInt:
+		SetInt(e.ref, e.i)
+	case TypeFloat:
+		SetFloat(e.ref, e.f)
+	case TypeDouble:
+		SetDouble(e.ref, e.d)
+	case TypeIntArray:
+		SetIntArray(e.ref, e.ints, 0)
+	case TypeFloatArray:
+		SetFloatArray(e.ref, e.floats, 0)
+	case TypeData:
+		SetBytes(e.ref, e.bytes)
+	}
+}
@@ -0,0 +1,108 @@
+package dref
+
+import (
+	"sort"
+	"unsafe"
+
+	"github.com/akhenakh/xplane-go/plugin"
+)
+
+// ReadyFunc is called once a pending dataref has been found and registered.
+type ReadyFunc func(name string)
+
+// RegisterPending registers a dataref that may not exist yet, typically one
+// published by another plugin that has not been loaded. If the dataref can be
+// found now it is registered immediately and onReady is called before
+// RegisterPending returns. Otherwise the name is kept pending and retried each
+// time the cache receives a message announcing new datarefs or a newly loaded
+// plane (see ReceiveMessage); onReady, if not nil, is called once it resolves.
+// It reports whether the dataref is ready.
+func (c *DataRefCache) RegisterPending(name string, onReady ReadyFunc) bool {
+	var callbacks []ReadyFunc
+	c.mutex.Lock()
+	if _, exists := c.refs[name]; !exists {
+		ref, err := FindDataRef(name)
+		if err != nil {
+			c.pending[name] = append(c.pending[name], onReady)
+			c.mutex.Unlock()
+			return false
+		}
+		callbacks = c.store(name, ref)
+	}
+	c.mutex.Unlock()
+
+	notifyReady(name, append(callbacks, onReady))
+	return true
+}
+
+// IsReady reports whether a dataref is registered and its owner is still loaded.
+func (c *DataRefCache) IsReady(name string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, ok := c.refs[name]
+	return ok && IsDataRefGood(entry.ref)
+}
+
+// Pending returns the names still waiting to be published, in sorted order.
+func (c *DataRefCache) Pending() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	names := make([]string, 0, len(c.pending))
+	for name := range c.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolvePending retries every pending dataref and returns how many were
+// found. Ready callbacks are called after the cache has been updated.
+func (c *DataRefCache) ResolvePending() int {
+	type resolved struct {
+		name      string
+		callbacks []ReadyFunc
+	}
+	var ready []resolved
+
+	c.mutex.Lock()
+	for name := range c.pending {
+		ref, err := FindDataRef(name)
+		if err != nil {
+			continue
+		}
+		ready = append(ready, resolved{name, c.store(name, ref)})
+	}
+	c.mutex.Unlock()
+
+	// Notify in a stable order so logs are reproducible.
+	sort.Slice(ready, func(i, j int) bool { return ready[i].name < ready[j].name })
+	for _, r := range ready {
+		notifyReady(r.name, r.callbacks)
+	}
+	return len(ready)
+}
+
+// notifyReady calls the ready callbacks of a dataref that was just found. It
+// must be called without holding the cache lock.
+func notifyReady(name string, callbacks []ReadyFunc) {
+	for _, onReady := range callbacks {
+		if onReady != nil {
+			onReady(name)
+		}
+	}
+}
+
+// ReceiveMessage implements plugin.MessageHandler. Forward the plugin's
+// messages to it so pending datarefs are retried when other plugins publish
+// datarefs or a plane is loaded.
+func (c *DataRefCache) ReceiveMessage(from plugin.PluginID, msg plugin.Message, param unsafe.Pointer) {
+	switch msg {
+	case plugin.MsgDataRefsAdded, plugin.MsgPlaneLoaded:
+		c.mutex.RLock()
+		hasPending := len(c.pending) > 0
+		c.mutex.RUnlock()
+		if hasPending {
+			c.ResolvePending()
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package dref
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+)
+
+func TestRegisterPending(t *testing.T) {
+	cache := NewDataRefCache()
+	var ready []string
+	onReady := func(name string) { ready = append(ready, name) }
+
+	if cache.RegisterPending("xplane-go/test/late_a", onReady) {
+		t.Fatal("missing dataref reported ready")
+	}
+	cache.RegisterPending("xplane-go/test/late_b", onReady)
+	if got := cache.Pending(); !slices.Equal(got, []string{"xplane-go/test/late_a", "xplane-go/test/late_b"}) {
+		t.Errorf("Pending() = %v", got)
+	}
+
+	xplmfake.AddDataRef("xplane-go/test/late_a", xplmfake.TypeFloat, true)
+	xplmfake.AddDataRef("xplane-go/test/late_b", xplmfake.TypeFloat, true)
+	// Registering directly resolves the pending name and calls its callbacks.
+	if err := cache.Register("xplane-go/test/late_a"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ready, []string{"xplane-go/test/late_a"}) {
+		t.Errorf("ready = %v after Register", ready)
+	}
+	if got := cache.Pending(); !slices.Equal(got, []string{"xplane-go/test/late_b"}) {
+		t.Errorf("Pending() = %v after Register", got)
+	}
+
+	// So does registering it pending again, before the queued callback.
+	ready = nil
+	cache.RegisterPending("xplane-go/test/late_b", func(name string) { ready = append(ready, "again") })
+	if !slices.Equal(ready, []string{"xplane-go/test/late_b", "again"}) {
+		t.Errorf("ready = %v after RegisterPending", ready)
+	}
+	if got := cache.Pending(); len(got) != 0 {
+		t.Errorf("Pending() = %v, want none", got)
+	}
+	if n := cache.ResolvePending(); n != 0 {
+		t.Errorf("ResolvePending resolved %d datarefs", n)
+	}
+}
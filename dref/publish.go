@@ -0,0 +1,480 @@
+package dref
+
+// #cgo CFLAGS: -DXPLM410=1
+// #include <stdlib.h>
+// #include "XPLMDataAccess.h"
+//
+// extern int dataGetInt_cgo(void* inRefcon);
+// extern void dataSetInt_cgo(void* inRefcon, int inValue);
+// extern float dataGetFloat_cgo(void* inRefcon);
+// extern void dataSetFloat_cgo(void* inRefcon, float inValue);
+// extern double dataGetDouble_cgo(void* inRefcon);
+// extern void dataSetDouble_cgo(void* inRefcon, double inValue);
+// extern int dataGetIntArray_cgo(void* inRefcon, int* outValues, int inOffset, int inMax);
+// extern void dataSetIntArray_cgo(void* inRefcon, int* inValues, int inOffset, int inCount);
+// extern int dataGetFloatArray_cgo(void* inRefcon, float* outValues, int inOffset, int inMax);
+// extern void dataSetFloatArray_cgo(void* inRefcon, float* inValues, int inOffset, int inCount);
+// extern int dataGetBytes_cgo(void* inRefcon, void* outValue, int inOffset, int inMaxLength);
+// extern void dataSetBytes_cgo(void* inRefcon, void* inValue, int inOffset, int inLength);
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/util"
+)
+
+var (
+	ErrNoGetter         = errors.New("dataref accessor has no getter")
+	ErrAlreadyPublished = errors.New("dataref already published by this plugin")
+	ErrPublishFailed    = errors.New("X-Plane refused to register the dataref accessor")
+	ErrDataRefNotOwned  = errors.New("dataref was not published by this plugin")
+)
+
+// Accessor holds the Go callbacks backing a dataref published by the plugin.
+// Only the callbacks that are set are exposed to X-Plane; the dataref type is
+// derived from the getters, and the dataref is writable if any setter is set.
+//
+// Array and byte getters follow the X-Plane convention: when out is nil they
+// return the total number of elements, otherwise they copy up to len(out)
+// elements starting at offset and return how many were copied.
+type Accessor struct {
+	GetInt    func() int
+	SetInt    func(value int)
+	GetFloat  func() float32
+	SetFloat  func(value float32)
+	GetDouble func() float64
+	SetDouble func(value float64)
+
+	GetIntArray   func(out []int32, offset int) int
+	SetIntArray   func(values []int32, offset int)
+	GetFloatArray func(out []float32, offset int) int
+	SetFloatArray func(values []float32, offset int)
+	GetBytes      func(out []byte, offset int) int
+	SetBytes      func(data []byte, offset int)
+}
+
+// dataType computes the XPLM type mask advertised for the accessor.
+func (a *Accessor) dataType() DataType {
+	var t DataType
+	if a.GetInt != nil {
+		t |= TypeInt
+	}
+	if a.GetFloat != nil {
+		t |= TypeFloat
+	}
+	if a.GetDouble != nil {
+		t |= TypeDouble
+	}
+	if a.GetIntArray != nil {
+		t |= TypeIntArray
+	}
+	if a.GetFloatArray != nil {
+		t |= TypeFloatArray
+	}
+	if a.GetBytes != nil {
+		t |= TypeData
+	}
+	return t
+}
+
+// writable reports whether any setter is set.
+func (a *Accessor) writable() bool {
+	return a.SetInt != nil || a.SetFloat != nil || a.SetDouble != nil ||
+		a.SetIntArray != nil || a.SetFloatArray != nil || a.SetBytes != nil
+}
+
+type publishedRef struct {
+	name     string
+	ref      DataRef
+	accessor Accessor
+	failed   bool // Set when an accessor panicked; the dataref then reads as 0.
+}
+
+var (
+	accessorRegistry      = make(map[uintptr]*publishedRef)
+	accessorRegistryMutex sync.RWMutex
+	nextAccessorID        uintptr = 1
+
+	publishedByName = make(map[string]uintptr)
+	publishedByRef  = make(map[DataRef]uintptr)
+)
+
+func init() {
+	// Accessors point into Go memory that is about to be released; X-Plane
+	// must not call them once the plugin is disabled.
+	plugin.OnDisable(UnpublishAll)
+}
+
+func getAccessor(id uintptr) *Accessor {
+	accessorRegistryMutex.RLock()
+	defer accessorRegistryMutex.RUnlock()
+	if p, ok := accessorRegistry[id]; ok && !p.failed {
+		return &p.accessor
+	}
+	return nil
+}
+
+// disableAccessor returns the function that disables the accessors of a
+// dataref after one of them panicked.
+func disableAccessor(inRefcon unsafe.Pointer) func() {
+	return func() {
+		accessorRegistryMutex.Lock()
+		defer accessorRegistryMutex.Unlock()
+		if p, ok := accessorRegistry[uintptr(inRefcon)]; ok {
+			p.failed = true
+			util.DebugString(fmt.Sprintf("xplane-go: accessors of '%s' disabled\n", p.name))
+		}
+	}
+}
+
+// CGO Trampolines. A panicking accessor disables all accessors of its dataref,
+// which then reads as 0 and ignores writes.
+
+//export dataGetInt_cgo
+func dataGetInt_cgo(inRefcon unsafe.Pointer) C.int {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	if a := getAccessor(uintptr(inRefcon)); a != nil && a.GetInt != nil {
+		return C.int(a.GetInt())
+	}
+	return 0
+}
+
+//export dataSetInt_cgo
+func dataSetInt_cgo(inRefcon unsafe.Pointer, inValue C.int) {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	if a := getAccessor(uintptr(inRefcon)); a != nil && a.SetInt != nil {
+		a.SetInt(int(inValue))
+	}
+}
+
+//export dataGetFloat_cgo
+func dataGetFloat_cgo(inRefcon unsafe.Pointer) C.float {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	if a := getAccessor(uintptr(inRefcon)); a != nil && a.GetFloat != nil {
+		return C.float(a.GetFloat())
+	}
+	return 0
+}
+
+//export dataSetFloat_cgo
+func dataSetFloat_cgo(inRefcon unsafe.Pointer, inValue C.float) {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	if a := getAccessor(uintptr(inRefcon)); a != nil && a.SetFloat != nil {
+		a.SetFloat(float32(inValue))
+	}
+}
+
+//export dataGetDouble_cgo
+func dataGetDouble_cgo(inRefcon unsafe.Pointer) C.double {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	if a := getAccessor(uintptr(inRefcon)); a != nil && a.GetDouble != nil {
+		return C.double(a.GetDouble())
+	}
+	return 0
+}
+
+//export dataSetDouble_cgo
+func dataSetDouble_cgo(inRefcon unsafe.Pointer, inValue C.double) {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	if a := getAccessor(uintptr(inRefcon)); a != nil && a.SetDouble != nil {
+		a.SetDouble(float64(inValue))
+	}
+}
+
+//export dataGetIntArray_cgo
+func dataGetIntArray_cgo(inRefcon unsafe.Pointer, outValues *C.int, inOffset, inMax C.int) C.int {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	a := getAccessor(uintptr(inRefcon))
+	if a == nil || a.GetIntArray == nil {
+		return 0
+	}
+	var out []int32
+	if outValues != nil {
+		out = unsafe.Slice((*int32)(unsafe.Pointer(outValues)), int(inMax))
+	}
+	return C.int(a.GetIntArray(out, int(inOffset)))
+}
+
+//export dataSetIntArray_cgo
+func dataSetIntArray_cgo(inRefcon unsafe.Pointer, inValues *C.int, inOffset, inCount C.int) {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	a := getAccessor(uintptr(inRefcon))
+	if a == nil || a.SetIntArray == nil || inValues == nil {
+		return
+	}
+	a.SetIntArray(unsafe.Slice((*int32)(unsafe.Pointer(inValues)), int(inCount)), int(inOffset))
+}
+
+//export dataGetFloatArray_cgo
+func dataGetFloatArray_cgo(inRefcon unsafe.Pointer, outValues *C.float, inOffset, inMax C.int) C.int {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	a := getAccessor(uintptr(inRefcon))
+	if a == nil || a.GetFloatArray == nil {
+		return 0
+	}
+	var out []float32
+	if outValues != nil {
+		out = unsafe.Slice((*float32)(unsafe.Pointer(outValues)), int(inMax))
+	}
+	return C.int(a.GetFloatArray(out, int(inOffset)))
+}
+
+//export dataSetFloatArray_cgo
+func dataSetFloatArray_cgo(inRefcon unsafe.Pointer, inValues *C.float, inOffset, inCount C.int) {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	a := getAccessor(uintptr(inRefcon))
+	if a == nil || a.SetFloatArray == nil || inValues == nil {
+		return
+	}
+	a.SetFloatArray(unsafe.Slice((*float32)(unsafe.Pointer(inValues)), int(inCount)), int(inOffset))
+}
+
+//export dataGetBytes_cgo
+func dataGetBytes_cgo(inRefcon unsafe.Pointer, outValue unsafe.Pointer, inOffset, inMaxLength C.int) C.int {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	a := getAccessor(uintptr(inRefcon))
+	if a == nil || a.GetBytes == nil {
+		return 0
+	}
+	var out []byte
+	if outValue != nil {
+		out = unsafe.Slice((*byte)(outValue), int(inMaxLength))
+	}
+	return C.int(a.GetBytes(out, int(inOffset)))
+}
+
+//export dataSetBytes_cgo
+func dataSetBytes_cgo(inRefcon unsafe.Pointer, inValue unsafe.Pointer, inOffset, inLength C.int) {
+	defer util.RecoverPanic("dataref accessor", disableAccessor(inRefcon))
+	a := getAccessor(uintptr(inRefcon))
+	if a == nil || a.SetBytes == nil || inValue == nil {
+		return
+	}
+	a.SetBytes(unsafe.Slice((*byte)(inValue), int(inLength)), int(inOffset))
+}
+
+// Publish registers a new dataref owned by the plugin, backed by the callbacks
+// in acc. The dataref stays published until Unpublish is called or the plugin
+// is disabled.
+func Publish(name string, acc Accessor) (DataRef, error) {
+	dataType := acc.dataType()
+	if dataType == TypeUnknown {
+		return nil, fmt.Errorf("could not publish dataref '%s': %w", name, ErrNoGetter)
+	}
+
+	accessorRegistryMutex.Lock()
+	if _, exists := publishedByName[name]; exists {
+		accessorRegistryMutex.Unlock()
+		return nil, fmt.Errorf("could not publish dataref '%s': %w", name, ErrAlreadyPublished)
+	}
+	id := nextAccessorID
+	nextAccessorID++
+	entry := &publishedRef{name: name, accessor: acc}
+	accessorRegistry[id] = entry
+	publishedByName[name] = id
+	accessorRegistryMutex.Unlock()
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	var (
+		readInt         C.XPLMGetDatai_f
+		writeInt        C.XPLMSetDatai_f
+		readFloat       C.XPLMGetDataf_f
+		writeFloat      C.XPLMSetDataf_f
+		readDouble      C.XPLMGetDatad_f
+		writeDouble     C.XPLMSetDatad_f
+		readIntArray    C.XPLMGetDatavi_f
+		writeIntArray   C.XPLMSetDatavi_f
+		readFloatArray  C.XPLMGetDatavf_f
+		writeFloatArray C.XPLMSetDatavf_f
+		readData        C.XPLMGetDatab_f
+		writeData       C.XPLMSetDatab_f
+	)
+	if acc.GetInt != nil {
+		readInt = (C.XPLMGetDatai_f)(C.dataGetInt_cgo)
+	}
+	if acc.SetInt != nil {
+		writeInt = (C.XPLMSetDatai_f)(C.dataSetInt_cgo)
+	}
+	if acc.GetFloat != nil {
+		readFloat = (C.XPLMGetDataf_f)(C.dataGetFloat_cgo)
+	}
+	if acc.SetFloat != nil {
+		writeFloat = (C.XPLMSetDataf_f)(C.dataSetFloat_cgo)
+	}
+	if acc.GetDouble != nil {
+		readDouble = (C.XPLMGetDatad_f)(C.dataGetDouble_cgo)
+	}
+	if acc.SetDouble != nil {
+		writeDouble = (C.XPLMSetDatad_f)(C.dataSetDouble_cgo)
+	}
+	if acc.GetIntArray != nil {
+		readIntArray = (C.XPLMGetDatavi_f)(C.dataGetIntArray_cgo)
+	}
+	if acc.SetIntArray != nil {
+		writeIntArray = (C.XPLMSetDatavi_f)(C.dataSetIntArray_cgo)
+	}
+	if acc.GetFloatArray != nil {
+		readFloatArray = (C.XPLMGetDatavf_f)(C.dataGetFloatArray_cgo)
+	}
+	if acc.SetFloatArray != nil {
+		writeFloatArray = (C.XPLMSetDatavf_f)(C.dataSetFloatArray_cgo)
+	}
+	if acc.GetBytes != nil {
+		readData = (C.XPLMGetDatab_f)(C.dataGetBytes_cgo)
+	}
+	if acc.SetBytes != nil {
+		writeData = (C.XPLMSetDatab_f)(C.dataSetBytes_cgo)
+	}
+
+	writable := 0
+	if acc.writable() {
+		writable = 1
+	}
+
+	refcon := unsafe.Pointer(id)
+	ref := DataRef(C.XPLMRegisterDataAccessor(
+		cName,
+		C.XPLMDataTypeID(dataType),
+		C.int(writable),
+		readInt, writeInt,
+		readFloat, writeFloat,
+		readDouble, writeDouble,
+		readIntArray, writeIntArray,
+		readFloatArray, writeFloatArray,
+		readData, writeData,
+		refcon, refcon,
+	))
+
+	accessorRegistryMutex.Lock()
+	defer accessorRegistryMutex.Unlock()
+	if ref == nil {
+		delete(accessorRegistry, id)
+		delete(publishedByName, name)
+		return nil, fmt.Errorf("could not publish dataref '%s': %w", name, ErrPublishFailed)
+	}
+	entry.ref = ref
+	publishedByRef[ref] = id
+	return ref, nil
+}
+
+// Unpublish unregisters a dataref previously returned by Publish and releases
+// its Go callbacks.
+func Unpublish(ref DataRef) error {
+	accessorRegistryMutex.Lock()
+	id, ok := publishedByRef[ref]
+	if !ok {
+		accessorRegistryMutex.Unlock()
+		return ErrDataRefNotOwned
+	}
+	entry := accessorRegistry[id]
+	delete(publishedByRef, ref)
+	delete(publishedByName, entry.name)
+	accessorRegistryMutex.Unlock()
+
+	C.XPLMUnregisterDataAccessor(C.XPLMDataRef(ref))
+
+	// Only drop the callbacks once X-Plane can no longer call them.
+	accessorRegistryMutex.Lock()
+	delete(accessorRegistry, id)
+	accessorRegistryMutex.Unlock()
+	return nil
+}
+
+// UnpublishAll unregisters every dataref published by the plugin.
+// It is called automatically when the plugin is disabled.
+func UnpublishAll() {
+	accessorRegistryMutex.RLock()
+	refs := make([]DataRef, 0, len(publishedByRef))
+	for ref := range publishedByRef {
+		refs = append(refs, ref)
+	}
+	accessorRegistryMutex.RUnlock()
+
+	for _, ref := range refs {
+		Unpublish(ref)
+	}
+}
+
+// PublishInt publishes an integer dataref backed by a Go variable.
+// If writable is true, other plugins may change the variable through the dataref.
+func PublishInt(name string, value *int, writable bool) (DataRef, error) {
+	acc := Accessor{GetInt: func() int { return *value }}
+	if writable {
+		acc.SetInt = func(v int) { *value = v }
+	}
+	return Publish(name, acc)
+}
+
+// PublishFloat publishes a float dataref backed by a Go variable.
+func PublishFloat(name string, value *float32, writable bool) (DataRef, error) {
+	acc := Accessor{GetFloat: func() float32 { return *value }}
+	if writable {
+		acc.SetFloat = func(v float32) { *value = v }
+	}
+	return Publish(name, acc)
+}
+
+// PublishDouble publishes a double dataref backed by a Go variable.
+func PublishDouble(name string, value *float64, writable bool) (DataRef, error) {
+	acc := Accessor{GetDouble: func() float64 { return *value }}
+	if writable {
+		acc.SetDouble = func(v float64) { *value = v }
+	}
+	return Publish(name, acc)
+}
+
+// PublishIntArray publishes an integer array dataref backed by a Go slice.
+// The slice length is fixed for the lifetime of the dataref.
+func PublishIntArray(name string, values []int32, writable bool) (DataRef, error) {
+	acc := Accessor{GetIntArray: func(out []int32, offset int) int { return readArray(out, values, offset) }}
+	if writable {
+		acc.SetIntArray = func(in []int32, offset int) { writeArray(values, in, offset) }
+	}
+	return Publish(name, acc)
+}
+
+// PublishFloatArray publishes a float array dataref backed by a Go slice.
+// The slice length is fixed for the lifetime of the dataref.
+func PublishFloatArray(name string, values []float32, writable bool) (DataRef, error) {
+	acc := Accessor{GetFloatArray: func(out []float32, offset int) int { return readArray(out, values, offset) }}
+	if writable {
+		acc.SetFloatArray = func(in []float32, offset int) { writeArray(values, in, offset) }
+	}
+	return Publish(name, acc)
+}
+
+// PublishBytes publishes a byte (data) dataref backed by a Go slice.
+// The slice length is fixed for the lifetime of the dataref.
+func PublishBytes(name string, data []byte, writable bool) (DataRef, error) {
+	acc := Accessor{GetBytes: func(out []byte, offset int) int { return readArray(out, data, offset) }}
+	if writable {
+		acc.SetBytes = func(in []byte, offset int) { writeArray(data, in, offset) }
+	}
+	return Publish(name, acc)
+}
+
+// readArray implements the X-Plane array read convention on top of a Go slice.
+func readArray[T any](out, src []T, offset int) int {
+	if out == nil {
+		return len(src)
+	}
+	if offset < 0 || offset >= len(src) {
+		return 0
+	}
+	return copy(out, src[offset:])
+}
+
+// writeArray copies values into dst at offset, ignoring anything past its end.
+func writeArray[T any](dst, values []T, offset int) {
+	if offset < 0 || offset >= len(dst) {
+		return
+	}
+	copy(dst[offset:], values)
+}
@@ -0,0 +1,204 @@
+package dref
+
+import "fmt"
+
+// Scalar is the set of Go types a scalar dataref can be read as.
+type Scalar interface {
+	int | float32 | float64
+}
+
+// Element is the set of Go types an array or byte dataref can hold.
+type Element interface {
+	int32 | float32 | byte
+}
+
+// Ref is a type-checked handle to a scalar dataref. The dataref is looked up
+// and validated once by NewRef, so reads and writes go straight to the SDK
+// without any cache lookup.
+type Ref[T Scalar] struct {
+	name     string
+	ref      DataRef
+	writable bool
+}
+
+// NewRef finds a dataref and checks that it can be accessed as T.
+// It returns ErrTypeMismatch if X-Plane does not expose the dataref with that type.
+func NewRef[T Scalar](name string) (*Ref[T], error) {
+	ref, writable, err := findTyped(name, scalarType[T]())
+	if err != nil {
+		return nil, err
+	}
+	return &Ref[T]{name: name, ref: ref, writable: writable}, nil
+}
+
+// NewWritableRef is like NewRef but also fails with ErrDataRefReadOnly if the
+// dataref cannot be written to.
+func NewWritableRef[T Scalar](name string) (*Ref[T], error) {
+	r, err := NewRef[T](name)
+	if err != nil {
+		return nil, err
+	}
+	if !r.writable {
+		return nil, fmt.Errorf("dataref '%s': %w", name, ErrDataRefReadOnly)
+	}
+	return r, nil
+}
+
+// Name returns the dataref name the handle was created with.
+func (r *Ref[T]) Name() string { return r.name }
+
+// DataRef returns the underlying SDK handle.
+func (r *Ref[T]) DataRef() DataRef { return r.ref }
+
+// Writable reports whether the dataref accepts writes.
+func (r *Ref[T]) Writable() bool { return r.writable }
+
+// Get reads the current value of the dataref.
+func (r *Ref[T]) Get() T {
+	var v T
+	switch p := any(&v).(type) {
+	case *int:
+		*p = GetInt(r.ref)
+	case *float32:
+		*p = GetFloat(r.ref)
+	case *float64:
+		*p = GetDouble(r.ref)
+	}
+	return v
+}
+
+// Set writes a new value to the dataref.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (r *Ref[T]) Set(value T) error {
+	if !r.writable {
+		return fmt.Errorf("dataref '%s': %w", r.name, ErrDataRefReadOnly)
+	}
+	switch p := any(&value).(type) {
+	case *int:
+		SetInt(r.ref, *p)
+	case *float32:
+		SetFloat(r.ref, *p)
+	case *float64:
+		SetDouble(r.ref, *p)
+	}
+	return nil
+}
+
+// ArrayRef is a type-checked handle to an array dataref: []int32 for int
+// arrays, []float32 for float arrays and []byte for data datarefs.
+type ArrayRef[E Element] struct {
+	name     string
+	ref      DataRef
+	writable bool
+}
+
+// NewArrayRef finds a dataref and checks that it can be accessed as an array of E.
+func NewArrayRef[E Element](name string) (*ArrayRef[E], error) {
+	ref, writable, err := findTyped(name, elementType[E]())
+	if err != nil {
+		return nil, err
+	}
+	return &ArrayRef[E]{name: name, ref: ref, writable: writable}, nil
+}
+
+// NewWritableArrayRef is like NewArrayRef but also fails with
+// ErrDataRefReadOnly if the dataref cannot be written to.
+func NewWritableArrayRef[E Element](name string) (*ArrayRef[E], error) {
+	r, err := NewArrayRef[E](name)
+	if err != nil {
+		return nil, err
+	}
+	if !r.writable {
+		return nil, fmt.Errorf("dataref '%s': %w", name, ErrDataRefReadOnly)
+	}
+	return r, nil
+}
+
+// Name returns the dataref name the handle was created with.
+func (r *ArrayRef[E]) Name() string { return r.name }
+
+// DataRef returns the underlying SDK handle.
+func (r *ArrayRef[E]) DataRef() DataRef { return r.ref }
+
+// Writable reports whether the dataref accepts writes.
+func (r *ArrayRef[E]) Writable() bool { return r.writable }
+
+// Len returns the number of elements in the array.
+func (r *ArrayRef[E]) Len() int {
+	var zero E
+	switch any(zero).(type) {
+	case int32:
+		return IntArrayLen(r.ref)
+	case float32:
+		return FloatArrayLen(r.ref)
+	default:
+		return ArrayLen(r.ref)
+	}
+}
+
+// Read copies elements starting at offset into buffer and returns the number
+// of elements read.
+func (r *ArrayRef[E]) Read(buffer []E, offset int) int {
+	switch b := any(buffer).(type) {
+	case []int32:
+		return GetIntArray(r.ref, b, offset)
+	case []float32:
+		return GetFloatArray(r.ref, b, offset)
+	case []byte:
+		return GetBytesAt(r.ref, b, offset)
+	}
+	return 0
+}
+
+// Write copies values into the array starting at offset.
+// It returns ErrDataRefReadOnly if the dataref is not writable.
+func (r *ArrayRef[E]) Write(values []E, offset int) error {
+	if !r.writable {
+		return fmt.Errorf("dataref '%s': %w", r.name, ErrDataRefReadOnly)
+	}
+	switch v := any(values).(type) {
+	case []int32:
+		SetIntArray(r.ref, v, offset)
+	case []float32:
+		SetFloatArray(r.ref, v, offset)
+	case []byte:
+		SetBytesAt(r.ref, v, offset)
+	}
+	return nil
+}
+
+// findTyped looks up a dataref and checks that it supports the wanted type.
+func findTyped(name string, want DataType) (DataRef, bool, error) {
+	ref, err := FindDataRef(name)
+	if err != nil {
+		return nil, false, fmt.Errorf("dataref '%s': %w", name, err)
+	}
+	if have := GetDataRefTypes(ref); have&want == 0 {
+		return nil, false, fmt.Errorf("dataref '%s' is %s, not %s: %w", name, have, want, ErrTypeMismatch)
+	}
+	return ref, CanWriteDataRef(ref), nil
+}
+
+func scalarType[T Scalar]() DataType {
+	var zero T
+	switch any(zero).(type) {
+	case int:
+		return TypeInt
+	case float32:
+		return TypeFloat
+	default:
+		return TypeDouble
+	}
+}
+
+func elementType[E Element]() DataType {
+	var zero E
+	switch any(zero).(type) {
+	case int32:
+		return TypeIntArray
+	case float32:
+		return TypeFloatArray
+	default:
+		return TypeData
+	}
+}
@@ -0,0 +1,172 @@
+package dref
+
+// #cgo CFLAGS: -DXPLM410=1
+// #include <stdlib.h>
+// #include "XPLMDataAccess.h"
+//
+// extern void sharedDataChanged_cgo(void* inRefcon);
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/util"
+)
+
+var (
+	ErrSharedTypeMismatch = errors.New("shared data already exists with a different type")
+)
+
+// SharedData is a dataref created or joined through XPLMShareData. Every
+// plugin sharing the same name and type reads and writes the same value, and
+// each one is notified when it changes.
+type SharedData struct {
+	name     string
+	dataType DataType
+	ref      DataRef
+	id       uintptr
+	onChange func()
+	failed   bool // Set when onChange panicked; guarded by sharedRegistryMutex.
+}
+
+var (
+	sharedRegistry      = make(map[uintptr]*SharedData)
+	sharedRegistryMutex sync.RWMutex
+	nextSharedID        uintptr = 1
+)
+
+func init() {
+	// X-Plane keeps calling the notification callbacks until they are
+	// unshared, so release them with the rest of the plugin's resources.
+	plugin.OnDisable(UnshareAll)
+}
+
+func getShared(id uintptr) *SharedData {
+	sharedRegistryMutex.RLock()
+	defer sharedRegistryMutex.RUnlock()
+	return sharedRegistry[id]
+}
+
+// getOnChange returns the notification function of a shared data item, or
+// nil if it has none or it panicked.
+func getOnChange(id uintptr) func() {
+	sharedRegistryMutex.RLock()
+	defer sharedRegistryMutex.RUnlock()
+	if s, ok := sharedRegistry[id]; ok && !s.failed {
+		return s.onChange
+	}
+	return nil
+}
+
+// disableOnChange stops calling the notification function of a shared data
+// item after it panicked. The item stays shared.
+func disableOnChange(id uintptr) {
+	sharedRegistryMutex.Lock()
+	defer sharedRegistryMutex.Unlock()
+	if s, ok := sharedRegistry[id]; ok {
+		s.failed = true
+	}
+}
+
+//export sharedDataChanged_cgo
+func sharedDataChanged_cgo(inRefcon unsafe.Pointer) {
+	id := uintptr(inRefcon)
+	if onChange := getOnChange(id); onChange != nil {
+		// A panicking onChange is not called again.
+		defer util.RecoverPanic("shared data callback", func() { disableOnChange(id) })
+		onChange()
+	}
+}
+
+// ShareData creates the shared dataref name with the given type, or joins it
+// if another plugin already created it. onChange, if not nil, is called on
+// the simulator thread whenever a plugin writes a new value. It returns
+// ErrSharedTypeMismatch if the data already exists with another type.
+func ShareData(name string, dataType DataType, onChange func()) (*SharedData, error) {
+	s := &SharedData{name: name, dataType: dataType, onChange: onChange}
+
+	sharedRegistryMutex.Lock()
+	s.id = nextSharedID
+	nextSharedID++
+	sharedRegistry[s.id] = s
+	sharedRegistryMutex.Unlock()
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	if C.XPLMShareData(cName, C.XPLMDataTypeID(dataType), s.callback(), s.refcon()) == 0 {
+		s.forget()
+		return nil, fmt.Errorf("could not share data '%s' as %s: %w", name, dataType, ErrSharedTypeMismatch)
+	}
+
+	ref, err := FindDataRef(name)
+	if err != nil {
+		s.unshare()
+		return nil, fmt.Errorf("could not share data '%s': %w", name, err)
+	}
+	s.ref = ref
+	return s, nil
+}
+
+// Name returns the name of the shared data.
+func (s *SharedData) Name() string { return s.name }
+
+// Type returns the type the data was shared with.
+func (s *SharedData) Type() DataType { return s.dataType }
+
+// DataRef returns the handle used to read and write the shared value with the
+// regular accessors, e.g. dref.GetFloat(s.DataRef()).
+func (s *SharedData) DataRef() DataRef { return s.ref }
+
+// Unshare stops sharing the data. The value survives as long as another
+// plugin still shares it.
+func (s *SharedData) Unshare() {
+	if getShared(s.id) == nil {
+		return
+	}
+	s.unshare()
+}
+
+// UnshareAll unshares every shared data item joined by the plugin.
+// It is called automatically when the plugin is disabled.
+func UnshareAll() {
+	sharedRegistryMutex.RLock()
+	items := make([]*SharedData, 0, len(sharedRegistry))
+	for _, s := range sharedRegistry {
+		items = append(items, s)
+	}
+	sharedRegistryMutex.RUnlock()
+
+	for _, s := range items {
+		s.unshare()
+	}
+}
+
+func (s *SharedData) unshare() {
+	cName := C.CString(s.name)
+	defer C.free(unsafe.Pointer(cName))
+	C.XPLMUnshareData(cName, C.XPLMDataTypeID(s.dataType), s.callback(), s.refcon())
+	s.forget()
+}
+
+func (s *SharedData) forget() {
+	sharedRegistryMutex.Lock()
+	defer sharedRegistryMutex.Unlock()
+	delete(sharedRegistry, s.id)
+}
+
+// callback returns the notification trampoline, or nil when the caller did
+// not ask to be notified. The same value must be passed to share and unshare.
+func (s *SharedData) callback() C.XPLMDataChanged_f {
+	if s.onChange == nil {
+		return nil
+	}
+	return (C.XPLMDataChanged_f)(C.sharedDataChanged_cgo)
+}
+
+func (s *SharedData) refcon() unsafe.Pointer {
+	return unsafe.Pointer(s.id)
+}
@@ -0,0 +1,38 @@
+package dref
+
+// Spec describes a scalar dataref by name together with the Go type it is
+// read as. Specs are usually generated by cmd/drefgen from DataRefs.txt, so
+// names are checked at compile time instead of at Register time.
+//
+//	lat, err := datarefs.FlightmodelPositionLatitude.Ref()
+//	cache.Register(datarefs.FlightmodelPositionLatitude.Name)
+type Spec[T Scalar] struct {
+	Name     string
+	Units    string
+	Writable bool
+}
+
+// Ref finds the dataref and returns a type-checked handle to it.
+func (s Spec[T]) Ref() (*Ref[T], error) {
+	if s.Writable {
+		return NewWritableRef[T](s.Name)
+	}
+	return NewRef[T](s.Name)
+}
+
+// ArraySpec describes an array or byte dataref by name, element type and
+// declared length.
+type ArraySpec[E Element] struct {
+	Name     string
+	Units    string
+	Len      int
+	Writable bool
+}
+
+// Ref finds the dataref and returns a type-checked handle to it.
+func (s ArraySpec[E]) Ref() (*ArrayRef[E], error) {
+	if s.Writable {
+		return NewWritableArrayRef[E](s.Name)
+	}
+	return NewArrayRef[E](s.Name)
+}
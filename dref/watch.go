@@ -0,0 +1,316 @@
+package dref
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/processing"
+)
+
+// ErrWatcherDestroyed is returned when watching with a destroyed Watcher.
+var ErrWatcherDestroyed = errors.New("watcher destroyed")
+
+// WatchID identifies a subscription made on a Watcher.
+type WatchID int
+
+// ChangeFunc is called when a watched scalar dataref changes.
+type ChangeFunc func(old, new float64)
+
+// ArrayChangeFunc is called when a watched array dataref changes. The slices
+// are owned by the Watcher and are only valid for the duration of the call.
+type ArrayChangeFunc func(old, new []float64)
+
+type watch struct {
+	id          WatchID
+	name        string
+	ref         DataRef
+	types       DataType
+	deadband    float64
+	minInterval float32
+	onChange    ChangeFunc
+	onArray     ArrayChangeFunc
+
+	primed    bool
+	lastFired float32
+	last      float64
+	lastArray []float64
+	curArray  []float64
+	intBuf    []int32
+	floatBuf  []float32
+	removed   bool
+}
+
+// Watcher samples datarefs from a DataRefCache and calls back when their
+// value moves by more than a deadband. All the watchers of a phase share a
+// single flight loop. Callbacks are dispatched on the simulator thread, in the
+// order the watchers were created and the watches were added.
+type Watcher struct {
+	cache   *DataRefCache
+	phase   processing.FlightLoopPhase
+	clock   float32
+	watches []*watch
+	nextID  WatchID
+	stopped bool
+	mutex   sync.Mutex
+}
+
+// watchLoop is the flight loop shared by the watchers of a phase. It is
+// destroyed when its last watcher is.
+type watchLoop struct {
+	loop     *processing.FlightLoop
+	watchers []*Watcher
+}
+
+var (
+	watchLoops      = make(map[processing.FlightLoopPhase]*watchLoop)
+	watchLoopsMutex sync.Mutex
+)
+
+func init() {
+	// Stop the shared flight loops when the plugin goes away.
+	plugin.OnDisable(func() {
+		watchLoopsMutex.Lock()
+		var watchers []*Watcher
+		for _, wl := range watchLoops {
+			watchers = append(watchers, wl.watchers...)
+		}
+		watchLoopsMutex.Unlock()
+		for _, w := range watchers {
+			w.Destroy()
+		}
+	})
+}
+
+// NewWatcher creates a watcher sampled every frame by the flight loop of the
+// given phase. Call Destroy when the watcher is no longer needed.
+func NewWatcher(cache *DataRefCache, phase processing.FlightLoopPhase) *Watcher {
+	w := &Watcher{cache: cache, phase: phase, nextID: 1}
+	watchLoopsMutex.Lock()
+	defer watchLoopsMutex.Unlock()
+	wl := watchLoops[phase]
+	if wl == nil {
+		wl = &watchLoop{}
+		wl.loop = processing.NewFlightLoop(phase, wl.sample)
+		wl.loop.Schedule(-1, true)
+		watchLoops[phase] = wl
+	}
+	wl.watchers = append(wl.watchers, w)
+	return w
+}
+
+// Watch calls onChange when the scalar dataref name changes by more than
+// deadband since the last reported value, at most once every minInterval
+// seconds. The dataref is registered in the cache if needed. int, float and
+// double datarefs are all reported as float64. It fails with
+// ErrWatcherDestroyed once the watcher is destroyed.
+func (w *Watcher) Watch(name string, deadband float64, minInterval float32, onChange ChangeFunc) (WatchID, error) {
+	entry, err := w.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	if entry.types&(TypeInt|TypeFloat|TypeDouble) == 0 {
+		return 0, fmt.Errorf("dataref '%s' is %s, not a scalar: %w", name, entry.types, ErrTypeMismatch)
+	}
+	return w.add(&watch{
+		name:        name,
+		ref:         entry.ref,
+		types:       entry.types,
+		deadband:    deadband,
+		minInterval: minInterval,
+		onChange:    onChange,
+	})
+}
+
+// WatchArray calls onChange when any element of the int or float array
+// dataref name changes by more than deadband since the last reported values,
+// at most once every minInterval seconds.
+func (w *Watcher) WatchArray(name string, deadband float64, minInterval float32, onChange ArrayChangeFunc) (WatchID, error) {
+	entry, err := w.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	wt := &watch{
+		name:        name,
+		ref:         entry.ref,
+		types:       entry.types,
+		deadband:    deadband,
+		minInterval: minInterval,
+		onArray:     onChange,
+	}
+	var n int
+	switch {
+	case entry.types&TypeFloatArray != 0:
+		n = FloatArrayLen(entry.ref)
+		wt.floatBuf = make([]float32, n)
+	case entry.types&TypeIntArray != 0:
+		n = IntArrayLen(entry.ref)
+		wt.intBuf = make([]int32, n)
+	default:
+		return 0, fmt.Errorf("dataref '%s' is %s, not an array: %w", name, entry.types, ErrTypeMismatch)
+	}
+	wt.lastArray = make([]float64, n)
+	wt.curArray = make([]float64, n)
+	return w.add(wt)
+}
+
+// Unwatch removes a subscription. It is safe to call from a change callback.
+func (w *Watcher) Unwatch(id WatchID) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for i, wt := range w.watches {
+		if wt.id == id {
+			wt.removed = true
+			w.watches = append(w.watches[:i:i], w.watches[i+1:]...)
+			return
+		}
+	}
+}
+
+// Destroy stops sampling. The phase's flight loop is destroyed with its last
+// watcher. Watchers are destroyed automatically when the plugin is disabled.
+func (w *Watcher) Destroy() {
+	w.mutex.Lock()
+	if w.stopped {
+		w.mutex.Unlock()
+		return
+	}
+	w.stopped = true
+	for _, wt := range w.watches {
+		wt.removed = true
+	}
+	w.watches = nil
+	w.mutex.Unlock()
+
+	watchLoopsMutex.Lock()
+	wl := watchLoops[w.phase]
+	if i := slices.Index(wl.watchers, w); i >= 0 {
+		wl.watchers = append(wl.watchers[:i:i], wl.watchers[i+1:]...)
+	}
+	if len(wl.watchers) > 0 {
+		watchLoopsMutex.Unlock()
+		return
+	}
+	delete(watchLoops, w.phase)
+	watchLoopsMutex.Unlock()
+	wl.loop.Destroy()
+}
+
+func (w *Watcher) lookup(name string) (cachedRef, error) {
+	if err := w.cache.Register(name); err != nil {
+		return cachedRef{}, err
+	}
+	w.cache.mutex.RLock()
+	defer w.cache.mutex.RUnlock()
+	return *w.cache.refs[name], nil
+}
+
+func (w *Watcher) add(wt *watch) (WatchID, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.stopped {
+		return 0, fmt.Errorf("watching '%s': %w", wt.name, ErrWatcherDestroyed)
+	}
+	wt.id = w.nextID
+	w.nextID++
+	w.watches = append(w.watches, wt)
+	return wt.id, nil
+}
+
+// sample is the shared flight loop callback of a phase.
+func (wl *watchLoop) sample(elapsedSinceLastCall, elapsedTimeSinceLastFlightLoop float32, counter int) float32 {
+	// Iterate over a snapshot so callbacks may create or destroy watchers.
+	watchLoopsMutex.Lock()
+	watchers := wl.watchers
+	watchLoopsMutex.Unlock()
+
+	for _, w := range watchers {
+		w.sample(elapsedSinceLastCall)
+	}
+	return -1
+}
+
+func (w *Watcher) sample(elapsed float32) {
+	w.mutex.Lock()
+	w.clock += elapsed
+	now := w.clock
+	// Iterate over a snapshot so callbacks may add or remove watches.
+	watches := w.watches
+	w.mutex.Unlock()
+
+	for _, wt := range watches {
+		w.mutex.Lock()
+		removed := wt.removed
+		w.mutex.Unlock()
+		if removed {
+			continue
+		}
+		if wt.onArray != nil {
+			wt.sampleArray(now)
+		} else {
+			wt.sampleScalar(now)
+		}
+	}
+}
+
+func (wt *watch) sampleScalar(now float32) {
+	var v float64
+	switch {
+	case wt.types&TypeDouble != 0:
+		v = GetDouble(wt.ref)
+	case wt.types&TypeFloat != 0:
+		v = float64(GetFloat(wt.ref))
+	default:
+		v = float64(GetInt(wt.ref))
+	}
+
+	if !wt.primed {
+		wt.primed, wt.last, wt.lastFired = true, v, now
+		return
+	}
+	if math.Abs(v-wt.last) <= wt.deadband || now-wt.lastFired < wt.minInterval {
+		return
+	}
+	old := wt.last
+	wt.last, wt.lastFired = v, now
+	wt.onChange(old, v)
+}
+
+func (wt *watch) sampleArray(now float32) {
+	if wt.floatBuf != nil {
+		GetFloatArray(wt.ref, wt.floatBuf, 0)
+		for i, v := range wt.floatBuf {
+			wt.curArray[i] = float64(v)
+		}
+	} else {
+		GetIntArray(wt.ref, wt.intBuf, 0)
+		for i, v := range wt.intBuf {
+			wt.curArray[i] = float64(v)
+		}
+	}
+
+	if !wt.primed {
+		wt.primed, wt.lastFired = true, now
+		copy(wt.lastArray, wt.curArray)
+		return
+	}
+	if now-wt.lastFired < wt.minInterval {
+		return
+	}
+	changed := false
+	for i, v := range wt.curArray {
+		if math.Abs(v-wt.lastArray[i]) > wt.deadband {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	wt.lastFired = now
+	wt.onArray(wt.lastArray, wt.curArray)
+	copy(wt.lastArray, wt.curArray)
+}
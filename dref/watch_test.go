@@ -0,0 +1,93 @@
+package dref
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/processing"
+)
+
+// addFloats adds n writable float datarefs to the fake simulator and returns
+// their names.
+func addFloats(tb testing.TB, prefix string, n int) []string {
+	tb.Helper()
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("xplane-go/test/%s_%d", prefix, i)
+		xplmfake.AddDataRef(names[i], xplmfake.TypeFloat, true)
+	}
+	return names
+}
+
+func TestWatchersShareLoop(t *testing.T) {
+	names := addFloats(t, "watch", 2)
+	cache := NewDataRefCache()
+	loops := xplmfake.FlightLoops()
+
+	var got []float64
+	first := NewWatcher(cache, processing.AfterFlightModel)
+	second := NewWatcher(cache, processing.AfterFlightModel)
+	if n := xplmfake.FlightLoops() - loops; n != 1 {
+		t.Fatalf("two watchers created %d flight loops, want 1", n)
+	}
+	for _, w := range []*Watcher{first, second} {
+		if _, err := w.Watch(names[0], 1, 0, func(old, new float64) { got = append(got, new) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, err := second.Watch(names[1], 0, 0, func(old, new float64) { t.Error("removed watch called") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Unwatch(id)
+
+	ref, _ := FindDataRef(names[0])
+	xplmfake.Frame(0.05)
+	SetFloat(ref, 0.5)
+	xplmfake.Frame(0.05)
+	SetFloat(ref, 2)
+	other, _ := FindDataRef(names[1])
+	SetFloat(other, 5)
+	xplmfake.Frame(0.05)
+	if len(got) != 2 || got[0] != 2 || got[1] != 2 {
+		t.Errorf("callbacks got %v, want 2 from each watcher", got)
+	}
+
+	first.Destroy()
+	if n := xplmfake.FlightLoops() - loops; n != 1 {
+		t.Errorf("%d flight loops with one watcher left, want 1", n)
+	}
+	SetFloat(ref, 4)
+	xplmfake.Frame(0.05)
+	if len(got) != 3 {
+		t.Errorf("callbacks got %v, want only the second watcher's", got)
+	}
+	second.Destroy()
+	second.Destroy()
+	if n := xplmfake.FlightLoops() - loops; n != 0 {
+		t.Errorf("%d flight loops left after destroying the watchers", n)
+	}
+	if _, err := second.Watch(names[0], 0, 0, func(old, new float64) {}); !errors.Is(err, ErrWatcherDestroyed) {
+		t.Errorf("Watch after Destroy: err = %v, want ErrWatcherDestroyed", err)
+	}
+}
+
+func TestWatchersDestroyedOnDisable(t *testing.T) {
+	names := addFloats(t, "watch_disable", 1)
+	loops := xplmfake.FlightLoops()
+	w := NewWatcher(NewDataRefCache(), processing.BeforeFlightModel)
+	if _, err := w.Watch(names[0], 0, 0, func(old, new float64) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin.XPluginDisable()
+	if n := xplmfake.FlightLoops() - loops; n != 0 {
+		t.Errorf("%d flight loops left after disabling the plugin", n)
+	}
+	if _, err := w.Watch(names[0], 0, 0, func(old, new float64) {}); !errors.Is(err, ErrWatcherDestroyed) {
+		t.Errorf("Watch after disable: err = %v, want ErrWatcherDestroyed", err)
+	}
+}
@@ -0,0 +1,160 @@
+// Package dreftxt parses the DataRefs.txt and CommandRefs.txt files shipped
+// with X-Plane in Resources/plugins. It is pure Go and can be used by tools
+// that run outside the simulator.
+package dreftxt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DataRef is one entry of DataRefs.txt.
+type DataRef struct {
+	Name        string
+	Type        string // Base type, normally "int", "float", "double" or "byte".
+	Dims        []int  // Array dimensions, empty for scalars. 0 means unspecified.
+	Writable    bool
+	Units       string
+	Description string
+}
+
+// IsArray reports whether the dataref is an array or byte dataref.
+func (d DataRef) IsArray() bool {
+	return len(d.Dims) > 0
+}
+
+// Len returns the total number of elements of an array dataref, 0 for
+// scalars or arrays of unspecified size.
+func (d DataRef) Len() int {
+	if len(d.Dims) == 0 {
+		return 0
+	}
+	n := 1
+	for _, dim := range d.Dims {
+		n *= dim
+	}
+	return n
+}
+
+// Command is one entry of CommandRefs.txt.
+type Command struct {
+	Name        string
+	Description string
+}
+
+// ParseDataRefs reads DataRefs.txt. Each line holds tab-separated fields:
+// name, type (e.g. "float" or "int[8]"), writable ("y"/"n"), units and
+// description; only the first two are required. The version header on the
+// first line is skipped.
+func ParseDataRefs(r io.Reader) ([]DataRef, error) {
+	var refs []DataRef
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || (lineNo == 1 && isHeader(line)) {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			fields = strings.Fields(line)
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("dreftxt: line %d: missing type for %q", lineNo, line)
+		}
+
+		ref := DataRef{Name: strings.TrimSpace(fields[0])}
+		base, dims, err := parseType(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("dreftxt: line %d: %w", lineNo, err)
+		}
+		ref.Type, ref.Dims = base, dims
+		if len(fields) > 2 {
+			ref.Writable = strings.HasPrefix(strings.TrimSpace(fields[2]), "y")
+		}
+		if len(fields) > 3 {
+			ref.Units = strings.TrimSpace(fields[3])
+		}
+		if len(fields) > 4 {
+			ref.Description = strings.TrimSpace(strings.Join(fields[4:], " "))
+		}
+		refs = append(refs, ref)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("dreftxt: %w", err)
+	}
+	return refs, nil
+}
+
+// ParseCommands reads CommandRefs.txt, where each line holds a command name
+// followed by whitespace and an optional description.
+func ParseCommands(r io.Reader) ([]Command, error) {
+	var cmds []Command
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		name, desc, _ := strings.Cut(line, "\t")
+		if name == line {
+			name, desc, _ = strings.Cut(line, " ")
+		}
+		cmds = append(cmds, Command{
+			Name:        strings.TrimSpace(name),
+			Description: strings.TrimSpace(desc),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("dreftxt: %w", err)
+	}
+	return cmds, nil
+}
+
+// isHeader reports whether line is the "2 1200 <date>" version header.
+func isHeader(line string) bool {
+	first, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	_, err := strconv.Atoi(first)
+	return err == nil
+}
+
+// parseType splits a type such as "float[8]" or "int[4][2]" into its base
+// type and dimensions.
+func parseType(s string) (string, []int, error) {
+	base, rest, _ := strings.Cut(s, "[")
+	if base == "" {
+		return "", nil, fmt.Errorf("malformed dataref type %q", s)
+	}
+	if rest == "" {
+		if base == "byte" {
+			// Byte datarefs are always arrays, even when no size is given.
+			return base, []int{0}, nil
+		}
+		return base, nil, nil
+	}
+
+	var dims []int
+	for _, part := range strings.Split("["+rest, "[") {
+		if part == "" {
+			continue
+		}
+		size, ok := strings.CutSuffix(part, "]")
+		if !ok {
+			return "", nil, fmt.Errorf("malformed dataref type %q", s)
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			// Sizes such as "float[engines]" are not numeric.
+			n = 0
+		}
+		dims = append(dims, n)
+	}
+	return base, dims, nil
+}
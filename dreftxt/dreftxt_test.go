@@ -0,0 +1,77 @@
+package dreftxt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDataRefs = "2 1200 Build 120000 July 1 2025\r\n" +
+	"sim/flightmodel/position/latitude\tdouble\tn\tdegrees\tThe latitude of the aircraft\r\n" +
+	"sim/flightmodel/engine/ENGN_N1_\tfloat[16]\ty\tpercent\tN1 speed as percent of max (per engine)\r\n" +
+	"sim/aircraft/view/acf_tailnum\tbyte[40]\ty\tstring\tTail number\r\n" +
+	"sim/aircraft/view/acf_ICAO\tbyte\ty\tstring\r\n" +
+	"sim/flightmodel2/wing/flap1_deg\tfloat[32][2]\tn\tdegrees\r\n" +
+	"sim/cockpit2/engine/actuators/throttle_ratio\tfloat[engines]\ty\n" +
+	"\n" +
+	"sim/time/paused int\n"
+
+func TestParseDataRefs(t *testing.T) {
+	refs, err := ParseDataRefs(strings.NewReader(testDataRefs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DataRef{
+		{Name: "sim/flightmodel/position/latitude", Type: "double", Units: "degrees", Description: "The latitude of the aircraft"},
+		{Name: "sim/flightmodel/engine/ENGN_N1_", Type: "float", Dims: []int{16}, Writable: true, Units: "percent", Description: "N1 speed as percent of max (per engine)"},
+		{Name: "sim/aircraft/view/acf_tailnum", Type: "byte", Dims: []int{40}, Writable: true, Units: "string", Description: "Tail number"},
+		{Name: "sim/aircraft/view/acf_ICAO", Type: "byte", Dims: []int{0}, Writable: true, Units: "string"},
+		{Name: "sim/flightmodel2/wing/flap1_deg", Type: "float", Dims: []int{32, 2}, Units: "degrees"},
+		{Name: "sim/cockpit2/engine/actuators/throttle_ratio", Type: "float", Dims: []int{0}, Writable: true},
+		{Name: "sim/time/paused", Type: "int"},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("ParseDataRefs =\n%+v\nwant\n%+v", refs, want)
+	}
+
+	lens := []int{0, 16, 40, 0, 64, 0, 0}
+	for i, ref := range refs {
+		if got := ref.Len(); got != lens[i] {
+			t.Errorf("%s: Len() = %d, want %d", ref.Name, got, lens[i])
+		}
+		if got := ref.IsArray(); got != (i >= 1 && i <= 5) {
+			t.Errorf("%s: IsArray() = %t", ref.Name, got)
+		}
+	}
+}
+
+func TestParseDataRefsErrors(t *testing.T) {
+	for _, input := range []string{
+		"sim/no/type\n",
+		"sim/bad/type\tfloat[8\n",
+		"sim/bad/type\t[8]\n",
+	} {
+		if _, err := ParseDataRefs(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseDataRefs(%q) succeeded", input)
+		}
+	}
+}
+
+func TestParseCommands(t *testing.T) {
+	const input = "sim/operation/pause_toggle\tToggle pause.\n" +
+		"sim/operation/quit   Quit X-Plane.\n" +
+		"\n" +
+		"sim/lights/landing_lights_on\n"
+	cmds, err := ParseCommands(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Command{
+		{Name: "sim/operation/pause_toggle", Description: "Toggle pause."},
+		{Name: "sim/operation/quit", Description: "Quit X-Plane."},
+		{Name: "sim/lights/landing_lights_on"},
+	}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("ParseCommands = %+v, want %+v", cmds, want)
+	}
+}
@@ -0,0 +1,305 @@
+// Package filter smooths and differentiates noisy dataref values such as
+// vertical speed or G-load. The filters are pure Go and take the time step of
+// every sample, so they work with the variable frame times of a flight loop;
+// Stream feeds them from datarefs and can publish their output.
+package filter
+
+import "math"
+
+// Filter processes a signal one sample at a time.
+type Filter interface {
+	// Update feeds a sample taken dt seconds after the previous one and
+	// returns the new output. Samples with dt <= 0, e.g. while the simulator
+	// is paused, leave the output unchanged.
+	Update(x, dt float64) float64
+	// Value returns the last output.
+	Value() float64
+	// Reset forgets the history, so the next sample starts afresh.
+	Reset()
+}
+
+// EMA is a first-order exponential low-pass filter.
+type EMA struct {
+	tau    float64
+	value  float64
+	primed bool
+}
+
+// NewEMA creates an exponential filter with time constant tau seconds: after
+// a step, the output covers 63% of the distance in tau seconds.
+func NewEMA(tau float64) *EMA {
+	return &EMA{tau: tau}
+}
+
+func (f *EMA) Update(x, dt float64) float64 {
+	switch {
+	case !f.primed:
+		f.value, f.primed = x, true
+	case dt > 0:
+		f.value += (x - f.value) * (1 - math.Exp(-dt/f.tau))
+	}
+	return f.value
+}
+
+func (f *EMA) Value() float64 { return f.value }
+
+func (f *EMA) Reset() { f.value, f.primed = 0, false }
+
+// Butterworth is a second-order Butterworth low-pass filter. It removes noise
+// above the cutoff frequency more sharply than EMA, at the cost of a small
+// overshoot.
+type Butterworth struct {
+	cutoff             float64
+	dt                 float64 // Time step the coefficients were computed for.
+	b0, b1, b2, a1, a2 float64 // Filter coefficients.
+	x1, x2, y1, y2     float64 // Previous inputs and outputs.
+	primed             bool
+}
+
+// NewButterworth creates a low-pass filter with the given cutoff frequency in
+// Hz.
+func NewButterworth(cutoff float64) *Butterworth {
+	return &Butterworth{cutoff: cutoff}
+}
+
+// coefficients computes the bilinear transform of the analog filter for a
+// time step. Frame times vary slightly, so they are only recomputed when the
+// step changes by more than 1%.
+func (f *Butterworth) coefficients(dt float64) {
+	if f.dt > 0 && math.Abs(dt-f.dt) < f.dt*0.01 {
+		return
+	}
+	f.dt = dt
+	// Keep the cutoff below the Nyquist frequency of the current step.
+	fc := math.Min(f.cutoff, 0.45/dt)
+	k := math.Tan(math.Pi * fc * dt)
+	norm := 1 / (1 + math.Sqrt2*k + k*k)
+	f.b0 = k * k * norm
+	f.b1 = 2 * f.b0
+	f.b2 = f.b0
+	f.a1 = 2 * (k*k - 1) * norm
+	f.a2 = (1 - math.Sqrt2*k + k*k) * norm
+}
+
+func (f *Butterworth) Update(x, dt float64) float64 {
+	if !f.primed {
+		f.x1, f.x2, f.y1, f.y2 = x, x, x, x
+		f.primed = true
+		return x
+	}
+	if dt <= 0 {
+		return f.y1
+	}
+	f.coefficients(dt)
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+func (f *Butterworth) Value() float64 { return f.y1 }
+
+func (f *Butterworth) Reset() {
+	*f = Butterworth{cutoff: f.cutoff}
+}
+
+// MovingAverage averages the last n samples.
+type MovingAverage struct {
+	samples []float64
+	next    int
+	count   int
+	sum     float64
+}
+
+// NewMovingAverage creates a moving average over n samples.
+func NewMovingAverage(n int) *MovingAverage {
+	return &MovingAverage{samples: make([]float64, max(n, 1))}
+}
+
+func (f *MovingAverage) Update(x, dt float64) float64 {
+	if dt <= 0 && f.count > 0 {
+		return f.Value()
+	}
+	if f.count == len(f.samples) {
+		f.sum -= f.samples[f.next]
+	} else {
+		f.count++
+	}
+	f.samples[f.next] = x
+	f.sum += x
+	f.next = (f.next + 1) % len(f.samples)
+	// Recompute the sum once per lap, so rounding errors do not accumulate.
+	if f.next == 0 {
+		f.sum = 0
+		for _, v := range f.samples[:f.count] {
+			f.sum += v
+		}
+	}
+	return f.Value()
+}
+
+func (f *MovingAverage) Value() float64 {
+	if f.count == 0 {
+		return 0
+	}
+	return f.sum / float64(f.count)
+}
+
+func (f *MovingAverage) Reset() {
+	clear(f.samples)
+	f.next, f.count, f.sum = 0, 0, 0
+}
+
+// Deadband holds its output until the input moves more than a band away from
+// it, so that jitter around a steady value does not reach the output.
+type Deadband struct {
+	band   float64
+	value  float64
+	primed bool
+}
+
+// NewDeadband creates a filter ignoring changes of at most band.
+func NewDeadband(band float64) *Deadband {
+	return &Deadband{band: band}
+}
+
+func (f *Deadband) Update(x, dt float64) float64 {
+	if !f.primed || math.Abs(x-f.value) > f.band {
+		f.value, f.primed = x, true
+	}
+	return f.value
+}
+
+func (f *Deadband) Value() float64 { return f.value }
+
+func (f *Deadband) Reset() { f.value, f.primed = 0, false }
+
+// RateLimit follows its input at most rate units per second, like a slewing
+// gauge needle.
+type RateLimit struct {
+	rate   float64
+	value  float64
+	primed bool
+}
+
+// NewRateLimit creates a filter whose output changes by at most rate per
+// second.
+func NewRateLimit(rate float64) *RateLimit {
+	return &RateLimit{rate: rate}
+}
+
+func (f *RateLimit) Update(x, dt float64) float64 {
+	switch {
+	case !f.primed:
+		f.value, f.primed = x, true
+	case dt > 0:
+		step := f.rate * dt
+		f.value += math.Max(-step, math.Min(step, x-f.value))
+	}
+	return f.value
+}
+
+func (f *RateLimit) Value() float64 { return f.value }
+
+func (f *RateLimit) Reset() { f.value, f.primed = 0, false }
+
+// Derivative outputs the rate of change of its input per second, optionally
+// smoothed by an exponential filter since differentiation amplifies noise.
+type Derivative struct {
+	last   float64
+	primed bool
+	smooth *EMA
+	rate   float64
+}
+
+// NewDerivative creates a rate-of-change filter whose output is smoothed with
+// time constant tau seconds, or not at all if tau is 0.
+func NewDerivative(tau float64) *Derivative {
+	d := &Derivative{}
+	if tau > 0 {
+		d.smooth = NewEMA(tau)
+	}
+	return d
+}
+
+func (f *Derivative) Update(x, dt float64) float64 {
+	if !f.primed {
+		f.last, f.primed = x, true
+		return f.rate
+	}
+	if dt <= 0 {
+		return f.rate
+	}
+	rate := (x - f.last) / dt
+	f.last = x
+	if f.smooth != nil {
+		rate = f.smooth.Update(rate, dt)
+	}
+	f.rate = rate
+	return rate
+}
+
+func (f *Derivative) Value() float64 { return f.rate }
+
+func (f *Derivative) Reset() {
+	f.last, f.primed, f.rate = 0, false, 0
+	if f.smooth != nil {
+		f.smooth.Reset()
+	}
+}
+
+// Trend predicts the value of its input a few seconds ahead by extrapolating
+// its smoothed rate of change, like the airspeed trend vector of a PFD.
+type Trend struct {
+	horizon float64
+	level   *EMA
+	rate    *Derivative
+	value   float64
+}
+
+// NewTrend creates a predictor looking horizon seconds ahead, with input and
+// rate smoothed with time constant tau seconds.
+func NewTrend(horizon, tau float64) *Trend {
+	return &Trend{horizon: horizon, level: NewEMA(tau), rate: NewDerivative(tau)}
+}
+
+func (f *Trend) Update(x, dt float64) float64 {
+	level := f.level.Update(x, dt)
+	f.value = level + f.rate.Update(level, dt)*f.horizon
+	return f.value
+}
+
+func (f *Trend) Value() float64 { return f.value }
+
+// Rate returns the smoothed rate of change per second.
+func (f *Trend) Rate() float64 { return f.rate.Value() }
+
+func (f *Trend) Reset() {
+	f.level.Reset()
+	f.rate.Reset()
+	f.value = 0
+}
+
+// Chain feeds each sample through several filters in turn, e.g. a low-pass
+// filter followed by a Derivative.
+type Chain []Filter
+
+func (c Chain) Update(x, dt float64) float64 {
+	for _, f := range c {
+		x = f.Update(x, dt)
+	}
+	return x
+}
+
+func (c Chain) Value() float64 {
+	if len(c) == 0 {
+		return 0
+	}
+	return c[len(c)-1].Value()
+}
+
+func (c Chain) Reset() {
+	for _, f := range c {
+		f.Reset()
+	}
+}
@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"math"
+	"testing"
+)
+
+// run feeds inputs sampled every dt seconds and returns the outputs.
+func run(f Filter, dt float64, inputs ...float64) []float64 {
+	outputs := make([]float64, len(inputs))
+	for i, x := range inputs {
+		outputs[i] = f.Update(x, dt)
+	}
+	return outputs
+}
+
+func checkOutputs(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Errorf("%s: outputs = %v, want %v", name, got, want)
+			return
+		}
+	}
+}
+
+func TestEMA(t *testing.T) {
+	f := NewEMA(1)
+	k := 1 - math.Exp(-0.5)
+	checkOutputs(t, "EMA", run(f, 0.5, 0, 10, 10, 10), []float64{0, 10 * k, 10 * k * (2 - k), 10 * (1 - (1-k)*(1-k)*(1-k))})
+	if f.Update(0, 0) != f.Value() || f.Value() == 0 {
+		t.Error("EMA changed with dt = 0")
+	}
+	f.Reset()
+	checkOutputs(t, "EMA after Reset", run(f, 0.5, 7), []float64{7})
+}
+
+func TestButterworth(t *testing.T) {
+	f := NewButterworth(1)
+	// A step settles on the new value, overshooting by a few percent.
+	f.Update(0, 0.01)
+	var peak float64
+	for range 500 {
+		peak = math.Max(peak, f.Update(1, 0.01))
+	}
+	if math.Abs(f.Value()-1) > 1e-6 || peak < 1.01 || peak > 1.1 {
+		t.Errorf("step response ends at %v with peak %v", f.Value(), peak)
+	}
+
+	// Noise well above the cutoff is strongly attenuated.
+	f.Reset()
+	var amplitude float64
+	for i := range 1000 {
+		y := f.Update(math.Sin(2*math.Pi*20*float64(i)*0.01+0.5), 0.01)
+		if i > 500 {
+			amplitude = math.Max(amplitude, math.Abs(y))
+		}
+	}
+	if amplitude > 0.01 {
+		t.Errorf("20 Hz amplitude through a 1 Hz filter = %v", amplitude)
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	f := NewMovingAverage(3)
+	checkOutputs(t, "MovingAverage", run(f, 0.1, 3, 6, 9, 12, 0, 0, 0), []float64{3, 4.5, 6, 9, 7, 4, 0})
+	if f.Update(100, 0) != 0 {
+		t.Error("MovingAverage changed with dt = 0")
+	}
+	f.Reset()
+	checkOutputs(t, "MovingAverage after Reset", run(f, 0.1, 5), []float64{5})
+}
+
+func TestDeadband(t *testing.T) {
+	f := NewDeadband(1)
+	checkOutputs(t, "Deadband", run(f, 0.1, 10, 10.5, 9.2, 11.5, 11, 10.6, 10.4), []float64{10, 10, 10, 11.5, 11.5, 11.5, 10.4})
+	f.Reset()
+	checkOutputs(t, "Deadband after Reset", run(f, 0.1, 3, 3.5), []float64{3, 3})
+}
+
+func TestRateLimit(t *testing.T) {
+	f := NewRateLimit(10)
+	checkOutputs(t, "RateLimit", run(f, 0.5, 0, 20, 20, 20, 0, 14), []float64{0, 5, 10, 15, 10, 14})
+	if f.Update(100, 0) != 14 {
+		t.Error("RateLimit changed with dt = 0")
+	}
+	f.Reset()
+	checkOutputs(t, "RateLimit after Reset", run(f, 0.5, -3), []float64{-3})
+}
+
+func TestDerivative(t *testing.T) {
+	checkOutputs(t, "Derivative", run(NewDerivative(0), 0.5, 0, 1, 3, 3), []float64{0, 2, 4, 0})
+
+	// A steady climb is measured exactly once the smoothing settled.
+	f := NewDerivative(0.5)
+	for i := range 200 {
+		f.Update(float64(i)*0.05*1000/60, 0.05)
+	}
+	if math.Abs(f.Value()-1000.0/60) > 1e-6 {
+		t.Errorf("smoothed rate = %v, want %v", f.Value(), 1000.0/60)
+	}
+}
+
+func TestTrend(t *testing.T) {
+	f := NewTrend(10, 0.2)
+	for i := range 400 {
+		f.Update(100+2*float64(i)*0.05, 0.05)
+	}
+	// The input goes up 2 units per second. Sampled every dt, its smoothed
+	// level lags by dt(1-k)/k seconds, where k is the EMA gain.
+	k := 1 - math.Exp(-0.05/0.2)
+	want := 100 + 2*399*0.05 - 2*0.05*(1-k)/k + 2*10
+	if math.Abs(f.Rate()-2) > 1e-6 || math.Abs(f.Value()-want) > 1e-6 {
+		t.Errorf("trend = %v at rate %v, want %v at rate 2", f.Value(), f.Rate(), want)
+	}
+}
+
+func TestChain(t *testing.T) {
+	c := Chain{NewDeadband(1), NewDerivative(0)}
+	checkOutputs(t, "Chain", run(c, 1, 0, 0.5, 3, 3.2), []float64{0, 0, 3, 0})
+	if c.Value() != 0 || (Chain{}).Value() != 0 {
+		t.Error("Chain.Value is not the last filter's")
+	}
+}
@@ -0,0 +1,197 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/processing"
+)
+
+var (
+	ErrNotScalar = errors.New("dataref is not a scalar")
+)
+
+var (
+	active      = make(map[*Stream]bool)
+	activeMutex sync.Mutex
+)
+
+func init() {
+	// Stop sampling and unpublish the outputs when the plugin goes away.
+	plugin.OnDisable(func() {
+		activeMutex.Lock()
+		streams := make([]*Stream, 0, len(active))
+		for s := range active {
+			streams = append(streams, s)
+		}
+		activeMutex.Unlock()
+		for _, s := range streams {
+			s.Destroy()
+		}
+	})
+}
+
+// Channel is a dataref fed through a filter by a Stream.
+type Channel struct {
+	stream    *Stream
+	source    string
+	ref       dref.DataRef
+	types     dref.DataType
+	filter    Filter
+	value     float64
+	published []dref.DataRef
+}
+
+// Stream samples datarefs every frame in a single flight loop and feeds them
+// to their filters, using the flight loop's elapsed time as the time step.
+type Stream struct {
+	loop     *processing.FlightLoop
+	channels []*Channel
+	mutex    sync.Mutex
+
+	// Only used by sample.
+	sampled []*Channel
+	inputs  []float64
+}
+
+// NewStream creates a stream whose flight loop runs every frame in the given
+// phase. Call Destroy when it is no longer needed; streams are destroyed
+// automatically when the plugin is disabled.
+func NewStream(phase processing.FlightLoopPhase) *Stream {
+	s := &Stream{}
+	s.loop = processing.NewFlightLoop(phase, s.sample)
+	s.loop.Schedule(-1, true)
+	activeMutex.Lock()
+	active[s] = true
+	activeMutex.Unlock()
+	return s
+}
+
+// Add filters the int, float or double dataref source with f.
+func (s *Stream) Add(source string, f Filter) (*Channel, error) {
+	ref, err := dref.FindDataRef(source)
+	if err != nil {
+		return nil, fmt.Errorf("filter: dataref '%s': %w", source, err)
+	}
+	types := dref.GetDataRefTypes(ref)
+	if types&(dref.TypeInt|dref.TypeFloat|dref.TypeDouble) == 0 {
+		return nil, fmt.Errorf("filter: dataref '%s' is %s: %w", source, types, ErrNotScalar)
+	}
+	c := &Channel{stream: s, source: source, ref: ref, types: types, filter: f}
+	s.mutex.Lock()
+	s.channels = append(s.channels, c)
+	s.mutex.Unlock()
+	return c, nil
+}
+
+// Remove stops feeding a channel and unpublishes its datarefs.
+func (s *Stream) Remove(c *Channel) {
+	s.mutex.Lock()
+	for i, ch := range s.channels {
+		if ch == c {
+			s.channels = append(s.channels[:i], s.channels[i+1:]...)
+			break
+		}
+	}
+	published := c.published
+	c.published = nil
+	s.mutex.Unlock()
+	for _, ref := range published {
+		dref.Unpublish(ref)
+	}
+}
+
+// Destroy stops the flight loop and unpublishes the datarefs of every
+// channel.
+func (s *Stream) Destroy() {
+	s.mutex.Lock()
+	loop := s.loop
+	s.loop = nil
+	channels := s.channels
+	s.channels = nil
+	s.mutex.Unlock()
+	activeMutex.Lock()
+	delete(active, s)
+	activeMutex.Unlock()
+	if loop != nil {
+		loop.Destroy()
+	}
+	for _, c := range channels {
+		for _, ref := range c.published {
+			dref.Unpublish(ref)
+		}
+		c.published = nil
+	}
+}
+
+func (s *Stream) sample(elapsedSinceLastCall, _ float32, _ int) float32 {
+	s.mutex.Lock()
+	s.sampled = append(s.sampled[:0], s.channels...)
+	s.mutex.Unlock()
+
+	// The sources are read without the lock: a source may be published by a
+	// channel of this stream, whose accessor takes it.
+	s.inputs = s.inputs[:0]
+	for _, c := range s.sampled {
+		s.inputs = append(s.inputs, c.read())
+	}
+
+	dt := float64(elapsedSinceLastCall)
+	s.mutex.Lock()
+	for i, c := range s.sampled {
+		c.value = c.filter.Update(s.inputs[i], dt)
+	}
+	s.mutex.Unlock()
+	clear(s.sampled)
+	return -1
+}
+
+func (c *Channel) read() float64 {
+	switch {
+	case c.types&dref.TypeDouble != 0:
+		return dref.GetDouble(c.ref)
+	case c.types&dref.TypeFloat != 0:
+		return float64(dref.GetFloat(c.ref))
+	default:
+		return float64(dref.GetInt(c.ref))
+	}
+}
+
+// Source returns the name of the filtered dataref.
+func (c *Channel) Source() string {
+	return c.source
+}
+
+// Value returns the filter output as of the last frame.
+func (c *Channel) Value() float64 {
+	c.stream.mutex.Lock()
+	defer c.stream.mutex.Unlock()
+	return c.value
+}
+
+// Reset clears the history of the channel's filter.
+func (c *Channel) Reset() {
+	c.stream.mutex.Lock()
+	defer c.stream.mutex.Unlock()
+	c.filter.Reset()
+}
+
+// Publish exposes the filter output as the read-only float and double
+// dataref name. It is unpublished when the channel is removed, the stream is
+// destroyed or the plugin is disabled.
+func (c *Channel) Publish(name string) (dref.DataRef, error) {
+	ref, err := dref.Publish(name, dref.Accessor{
+		GetFloat:  func() float32 { return float32(c.Value()) },
+		GetDouble: c.Value,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	c.stream.mutex.Lock()
+	c.published = append(c.published, ref)
+	c.stream.mutex.Unlock()
+	return ref, nil
+}
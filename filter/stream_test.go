@@ -0,0 +1,73 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/processing"
+)
+
+func TestStream(t *testing.T) {
+	xplmfake.AddDataRef("xplane-go/test/filter/vs", xplmfake.TypeFloat, true)
+	vs, _ := dref.FindDataRef("xplane-go/test/filter/vs")
+	loops := xplmfake.FlightLoops()
+
+	s := NewStream(processing.AfterFlightModel)
+	defer s.Destroy()
+	avg, err := s.Add("xplane-go/test/filter/vs", NewMovingAverage(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := avg.Publish("xplane-go/test/filter/vs_avg"); err != nil {
+		t.Fatal(err)
+	}
+	// A chain may filter the stream's own output, read through its accessor.
+	slewed, err := s.Add("xplane-go/test/filter/vs_avg", NewRateLimit(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []float32{100, 300, 300} {
+		dref.SetFloat(vs, v)
+		xplmfake.Frame(1)
+	}
+	if avg.Value() != 300 || slewed.Value() != 200 {
+		t.Errorf("average = %v, slewed = %v, want 300 and 200", avg.Value(), slewed.Value())
+	}
+	out, _ := dref.FindDataRef("xplane-go/test/filter/vs_avg")
+	if got := dref.GetDouble(out); got != 300 {
+		t.Errorf("published average = %v, want 300", got)
+	}
+
+	s.Remove(avg)
+	if _, err := dref.FindDataRef("xplane-go/test/filter/vs_avg"); err == nil {
+		t.Error("output still published after Remove")
+	}
+	s.Destroy()
+	if n := xplmfake.FlightLoops() - loops; n != 0 {
+		t.Errorf("%d flight loops left after Destroy", n)
+	}
+}
+
+func TestStreamDestroyedOnDisable(t *testing.T) {
+	xplmfake.AddDataRef("xplane-go/test/filter/g", xplmfake.TypeDouble, false)
+	loops := xplmfake.FlightLoops()
+	s := NewStream(processing.BeforeFlightModel)
+	c, err := s.Add("xplane-go/test/filter/g", NewEMA(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Publish("xplane-go/test/filter/g_smooth"); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin.XPluginDisable()
+	if n := xplmfake.FlightLoops() - loops; n != 0 {
+		t.Errorf("%d flight loops left after disabling the plugin", n)
+	}
+	if _, err := dref.FindDataRef("xplane-go/test/filter/g_smooth"); err == nil {
+		t.Error("output still published after disabling the plugin")
+	}
+}
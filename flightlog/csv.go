@@ -0,0 +1,126 @@
+package flightlog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteCSV converts the remaining records of r to CSV. The first row holds
+// "time" followed by Header.Columns, and each following row one record.
+func WriteCSV(w io.Writer, r *Reader) error {
+	h := r.Header()
+	cw := csv.NewWriter(w)
+	row := append([]string{"time"}, h.Columns()...)
+	// Float32 channels are formatted at their own precision, so that 0.3
+	// prints as 0.3 and not as its float64 widening.
+	bits := make([]int, 0, h.Width())
+	for _, c := range h.Channels {
+		for range c.Count {
+			if c.Type == Float32 {
+				bits = append(bits, 32)
+			} else {
+				bits = append(bits, 64)
+			}
+		}
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		row = row[:0]
+		row = append(row, strconv.FormatFloat(rec.Time, 'f', -1, 64))
+		for i, v := range rec.Values {
+			row = append(row, strconv.FormatFloat(v, 'g', -1, bits[i]))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadCSV reads a log in the format written by WriteCSV: a "time" column
+// followed by one column per value, where consecutive name[i] columns form an
+// array channel. CSV does not record storage types, so every channel is
+// reported as Float64, and the rate is estimated from the first two rows.
+func ReadCSV(r io.Reader) (Header, []Record, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	row, err := cr.Read()
+	if err != nil {
+		return Header{}, nil, fmt.Errorf("flightlog: reading CSV header: %w", err)
+	}
+	if len(row) < 2 || row[0] != "time" {
+		return Header{}, nil, ErrCSVHeader
+	}
+
+	var h Header
+	for _, col := range row[1:] {
+		name, index, isArray := splitColumn(col)
+		last := len(h.Channels) - 1
+		if isArray && index > 0 && last >= 0 && h.Channels[last].Name == name && h.Channels[last].Count == index {
+			h.Channels[last].Count++
+			continue
+		}
+		h.Channels = append(h.Channels, Channel{Name: name, Type: Float64, Count: 1})
+	}
+	if err := h.validate(); err != nil {
+		return Header{}, nil, err
+	}
+
+	var records []Record
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Header{}, nil, fmt.Errorf("flightlog: %w", err)
+		}
+		rec := Record{Values: make([]float64, len(row)-1)}
+		if rec.Time, err = strconv.ParseFloat(row[0], 64); err != nil {
+			return Header{}, nil, fmt.Errorf("flightlog: line %d: %w", line, err)
+		}
+		for i, field := range row[1:] {
+			if rec.Values[i], err = strconv.ParseFloat(field, 64); err != nil {
+				return Header{}, nil, fmt.Errorf("flightlog: line %d: %w", line, err)
+			}
+		}
+		records = append(records, rec)
+	}
+	if len(records) > 1 {
+		if dt := records[1].Time - records[0].Time; dt > 0 {
+			h.Rate = 1 / dt
+		}
+	}
+	return h, records, nil
+}
+
+// splitColumn splits a column name such as "ENGN_N1_[3]" into its base name
+// and index.
+func splitColumn(col string) (string, int, bool) {
+	base, rest, ok := strings.Cut(col, "[")
+	if !ok {
+		return col, 0, false
+	}
+	index, ok := strings.CutSuffix(rest, "]")
+	if !ok {
+		return col, 0, false
+	}
+	n, err := strconv.Atoi(index)
+	if err != nil {
+		return col, 0, false
+	}
+	return base, n, true
+}
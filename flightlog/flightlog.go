@@ -0,0 +1,315 @@
+// Package flightlog reads and writes the compact binary flight logs produced
+// by the recorder package. It is pure Go, so logs can be inspected and
+// converted outside the simulator.
+//
+// A log starts with a header describing the sample rate, the start time and
+// every recorded channel, followed by fixed-size records holding the time
+// since the start and one value per channel element. Records are only ever
+// appended, so a log cut short by a crash stays readable up to its last
+// complete record.
+package flightlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// magic identifies a flight log file, followed by the format version.
+const (
+	magic   = "XPFLTLOG"
+	version = 1
+)
+
+var (
+	ErrBadMagic      = errors.New("flightlog: not a flight log")
+	ErrBadVersion    = errors.New("flightlog: unsupported version")
+	ErrRecordSize    = errors.New("flightlog: wrong number of values for record")
+	ErrNoChannels    = errors.New("flightlog: no channels")
+	ErrChannelFormat = errors.New("flightlog: invalid channel")
+	ErrCSVHeader     = errors.New("flightlog: CSV must start with a time column followed by values")
+)
+
+// ValueType is the storage type of a channel.
+type ValueType uint8
+
+const (
+	Int32 ValueType = iota + 1
+	Float32
+	Float64
+	Byte
+)
+
+// Size returns the number of bytes used to store one value.
+func (t ValueType) Size() int {
+	switch t {
+	case Int32, Float32:
+		return 4
+	case Float64:
+		return 8
+	case Byte:
+		return 1
+	}
+	return 0
+}
+
+func (t ValueType) String() string {
+	switch t {
+	case Int32:
+		return "int32"
+	case Float32:
+		return "float32"
+	case Float64:
+		return "float64"
+	case Byte:
+		return "byte"
+	}
+	return fmt.Sprintf("ValueType(%d)", uint8(t))
+}
+
+// Channel is a recorded dataref. Count is the number of array elements
+// stored per record, 1 for scalars.
+type Channel struct {
+	Name  string
+	Type  ValueType
+	Count int
+}
+
+// Header describes the content of a log.
+type Header struct {
+	Rate     float64   // Samples per second.
+	Start    time.Time // Wall-clock time of the first sample.
+	Channels []Channel
+}
+
+// Width returns the number of values in each record, i.e. the sum of the
+// channel counts.
+func (h *Header) Width() int {
+	n := 0
+	for _, c := range h.Channels {
+		n += c.Count
+	}
+	return n
+}
+
+// RecordSize returns the size in bytes of one record.
+func (h *Header) RecordSize() int {
+	n := 8 // Time.
+	for _, c := range h.Channels {
+		n += c.Count * c.Type.Size()
+	}
+	return n
+}
+
+// Columns returns a name for every value of a record: the channel name for
+// scalars and name[i] for array elements.
+func (h *Header) Columns() []string {
+	cols := make([]string, 0, h.Width())
+	for _, c := range h.Channels {
+		if c.Count == 1 {
+			cols = append(cols, c.Name)
+			continue
+		}
+		for i := range c.Count {
+			cols = append(cols, fmt.Sprintf("%s[%d]", c.Name, i))
+		}
+	}
+	return cols
+}
+
+func (h *Header) validate() error {
+	if len(h.Channels) == 0 {
+		return ErrNoChannels
+	}
+	for _, c := range h.Channels {
+		if c.Name == "" || len(c.Name) > math.MaxUint16 || c.Type.Size() == 0 || c.Count <= 0 || c.Count > math.MaxUint16 {
+			return fmt.Errorf("%w: %q (%s x %d)", ErrChannelFormat, c.Name, c.Type, c.Count)
+		}
+	}
+	return nil
+}
+
+// Record is one sample of every channel. Values are flattened in channel
+// order and widened to float64, which represents every stored type exactly.
+type Record struct {
+	Time   float64 // Seconds since Header.Start.
+	Values []float64
+}
+
+// Writer appends records to a log.
+type Writer struct {
+	w      *bufio.Writer
+	header Header
+	buf    []byte
+}
+
+// NewWriter writes the header to w and returns a Writer for its records.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(w)
+	buf := append([]byte(magic), 0, 0)
+	binary.LittleEndian.PutUint16(buf[len(magic):], version)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(h.Rate))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.Start.UnixNano()))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(h.Channels)))
+	for _, c := range h.Channels {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(c.Name)))
+		buf = append(buf, c.Name...)
+		buf = append(buf, byte(c.Type))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(c.Count))
+	}
+	if _, err := bw.Write(buf); err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw, header: h, buf: make([]byte, h.RecordSize())}, nil
+}
+
+// Header returns the header the log was created with.
+func (w *Writer) Header() Header {
+	return w.header
+}
+
+// Write appends a record. values must hold Header.Width values in channel
+// order; they are converted to each channel's storage type.
+func (w *Writer) Write(t float64, values []float64) error {
+	if len(values) != w.header.Width() {
+		return fmt.Errorf("%w: got %d, want %d", ErrRecordSize, len(values), w.header.Width())
+	}
+	buf := w.buf[:0]
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(t))
+	i := 0
+	for _, c := range w.header.Channels {
+		for range c.Count {
+			v := values[i]
+			i++
+			switch c.Type {
+			case Int32:
+				buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(v)))
+			case Float32:
+				buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v)))
+			case Float64:
+				buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+			case Byte:
+				buf = append(buf, byte(v))
+			}
+		}
+	}
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Flush writes buffered records to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Reader reads records from a log.
+type Reader struct {
+	r      *bufio.Reader
+	header Header
+	buf    []byte
+}
+
+// NewReader reads the header of a log.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	fixed := make([]byte, len(magic)+2+8+8+2)
+	if _, err := io.ReadFull(br, fixed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrBadMagic
+		}
+		return nil, err
+	}
+	if string(fixed[:len(magic)]) != magic {
+		return nil, ErrBadMagic
+	}
+	p := fixed[len(magic):]
+	if v := binary.LittleEndian.Uint16(p); v != version {
+		return nil, fmt.Errorf("%w: %d", ErrBadVersion, v)
+	}
+	var h Header
+	h.Rate = math.Float64frombits(binary.LittleEndian.Uint64(p[2:]))
+	h.Start = time.Unix(0, int64(binary.LittleEndian.Uint64(p[10:])))
+	h.Channels = make([]Channel, binary.LittleEndian.Uint16(p[18:]))
+
+	var small [3]byte
+	for i := range h.Channels {
+		if _, err := io.ReadFull(br, small[:2]); err != nil {
+			return nil, fmt.Errorf("flightlog: reading channel %d: %w", i, err)
+		}
+		name := make([]byte, binary.LittleEndian.Uint16(small[:2]))
+		if _, err := io.ReadFull(br, name); err != nil {
+			return nil, fmt.Errorf("flightlog: reading channel %d: %w", i, err)
+		}
+		if _, err := io.ReadFull(br, small[:3]); err != nil {
+			return nil, fmt.Errorf("flightlog: reading channel %d: %w", i, err)
+		}
+		h.Channels[i] = Channel{
+			Name:  string(name),
+			Type:  ValueType(small[0]),
+			Count: int(binary.LittleEndian.Uint16(small[1:])),
+		}
+	}
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	return &Reader{r: br, header: h, buf: make([]byte, h.RecordSize())}, nil
+}
+
+// Header returns the header of the log.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next reads the next record. It returns io.EOF at the end of the log,
+// including when the log ends with an incomplete record.
+func (r *Reader) Next() (Record, error) {
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return Record{}, err
+	}
+	rec := Record{
+		Time:   math.Float64frombits(binary.LittleEndian.Uint64(r.buf)),
+		Values: make([]float64, 0, r.header.Width()),
+	}
+	p := r.buf[8:]
+	for _, c := range r.header.Channels {
+		for range c.Count {
+			switch c.Type {
+			case Int32:
+				rec.Values = append(rec.Values, float64(int32(binary.LittleEndian.Uint32(p))))
+			case Float32:
+				rec.Values = append(rec.Values, float64(math.Float32frombits(binary.LittleEndian.Uint32(p))))
+			case Float64:
+				rec.Values = append(rec.Values, math.Float64frombits(binary.LittleEndian.Uint64(p)))
+			case Byte:
+				rec.Values = append(rec.Values, float64(p[0]))
+			}
+			p = p[c.Type.Size():]
+		}
+	}
+	return rec, nil
+}
+
+// ReadAll reads every remaining record.
+func (r *Reader) ReadAll() ([]Record, error) {
+	var records []Record
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
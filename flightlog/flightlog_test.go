@@ -0,0 +1,146 @@
+package flightlog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testHeader = Header{
+	Rate:  20,
+	Start: time.Date(2025, 6, 1, 14, 30, 0, 123456789, time.UTC),
+	Channels: []Channel{
+		{Name: "sim/flightmodel/position/latitude", Type: Float64, Count: 1},
+		{Name: "sim/flightmodel/position/indicated_airspeed", Type: Float32, Count: 1},
+		{Name: "sim/flightmodel/engine/ENGN_N1_", Type: Float32, Count: 2},
+		{Name: "sim/cockpit/switches/gear_handle_status", Type: Int32, Count: 1},
+		{Name: "sim/aircraft/view/acf_ICAO", Type: Byte, Count: 4},
+	},
+}
+
+var testRecords = []Record{
+	{Time: 0, Values: []float64{47.4582131, 0.5, 20.25, 21.5, 1, 'C', '1', '7', '2'}},
+	{Time: 0.05, Values: []float64{47.4582185, 61.75, 85, 84.5, 0, 'C', '1', '7', '2'}},
+	{Time: 0.1, Values: []float64{-33.9399228, -1.25, 0, 100, -1, 0, 0, 0, 255}},
+}
+
+func writeLog(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range testRecords {
+		if err := w.Write(rec.Time, rec.Values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(writeLog(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := r.Header()
+	if h.Rate != testHeader.Rate || !h.Start.Equal(testHeader.Start) || !reflect.DeepEqual(h.Channels, testHeader.Channels) {
+		t.Errorf("header = %+v, want %+v", h, testHeader)
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, testRecords) {
+		t.Errorf("records = %v, want %v", records, testRecords)
+	}
+}
+
+func TestTruncatedLog(t *testing.T) {
+	data := writeLog(t)
+	// A crash in the middle of the last record.
+	r, err := NewReader(bytes.NewReader(data[:len(data)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, testRecords[:2]) {
+		t.Errorf("records = %v, want the first two", records)
+	}
+}
+
+func TestInvalidLogs(t *testing.T) {
+	data := writeLog(t)
+	badVersion := bytes.Clone(data)
+	badVersion[len(magic)] = 99
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrBadMagic},
+		{"not a log", []byte("time,sim/a\n0,1\n"), ErrBadMagic},
+		{"version", badVersion, ErrBadVersion},
+	} {
+		if _, err := NewReader(bytes.NewReader(tc.data)); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	w, err := NewWriter(io.Discard, testHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(0, []float64{1, 2}); !errors.Is(err, ErrRecordSize) {
+		t.Errorf("Write with 2 values: err = %v, want ErrRecordSize", err)
+	}
+	if _, err := NewWriter(io.Discard, Header{Rate: 1}); !errors.Is(err, ErrNoChannels) {
+		t.Errorf("NewWriter without channels: err = %v, want ErrNoChannels", err)
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(writeLog(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var csv strings.Builder
+	if err := WriteCSV(&csv, r); err != nil {
+		t.Fatal(err)
+	}
+	firstLine, _, _ := strings.Cut(csv.String(), "\n")
+	if want := "time,sim/flightmodel/position/latitude,sim/flightmodel/position/indicated_airspeed," +
+		"sim/flightmodel/engine/ENGN_N1_[0],sim/flightmodel/engine/ENGN_N1_[1]," +
+		"sim/cockpit/switches/gear_handle_status," +
+		"sim/aircraft/view/acf_ICAO[0],sim/aircraft/view/acf_ICAO[1],sim/aircraft/view/acf_ICAO[2],sim/aircraft/view/acf_ICAO[3]"; firstLine != want {
+		t.Errorf("CSV header = %q", firstLine)
+	}
+
+	h, records, err := ReadCSV(strings.NewReader(csv.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Rate != testHeader.Rate {
+		t.Errorf("rate = %v, want %v", h.Rate, testHeader.Rate)
+	}
+	for i, c := range h.Channels {
+		if want := testHeader.Channels[i]; c.Name != want.Name || c.Count != want.Count || c.Type != Float64 {
+			t.Errorf("channel %d = %+v, want %s x %d as float64", i, c, want.Name, want.Count)
+		}
+	}
+	if !reflect.DeepEqual(records, testRecords) {
+		t.Errorf("records = %v, want %v", records, testRecords)
+	}
+}
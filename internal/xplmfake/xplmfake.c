@@ -0,0 +1,485 @@
+#include <stdlib.h>
+#include <string.h>
+
+#include "xplmfake.h"
+
+// Slots are never reused, so that a stale handle cannot reach a newer
+// dataref or flight loop.
+#define MAX_DATAREFS 4096
+#define MAX_LOOPS 4096
+#define MAX_ELEMENTS 64
+#define MAX_BYTES 256
+
+typedef struct {
+	char* name;
+	XPLMDataTypeID types;
+	int writable;
+	int live;
+
+	// Storage of the datarefs added with fake_add_dataref or XPLMShareData.
+	double value;
+	int ints[MAX_ELEMENTS];
+	float floats[MAX_ELEMENTS];
+	char bytes[MAX_BYTES];
+
+	// Callbacks of the datarefs published with XPLMRegisterDataAccessor.
+	int published;
+	XPLMGetDatai_f geti;
+	XPLMSetDatai_f seti;
+	XPLMGetDataf_f getf;
+	XPLMSetDataf_f setf;
+	XPLMGetDatad_f getd;
+	XPLMSetDatad_f setd;
+	XPLMGetDatavi_f getvi;
+	XPLMSetDatavi_f setvi;
+	XPLMGetDatavf_f getvf;
+	XPLMSetDatavf_f setvf;
+	XPLMGetDatab_f getb;
+	XPLMSetDatab_f setb;
+	void* readRefcon;
+	void* writeRefcon;
+} dataref;
+
+static dataref datarefs[MAX_DATAREFS];
+static int ndatarefs;
+
+static dataref* live_dataref(XPLMDataRef ref) {
+	dataref* d = ref;
+	return d != NULL && d->live ? d : NULL;
+}
+
+static dataref* writable_dataref(XPLMDataRef ref) {
+	dataref* d = live_dataref(ref);
+	return d != NULL && d->writable ? d : NULL;
+}
+
+static dataref* new_dataref(const char* name, XPLMDataTypeID types, int writable) {
+	if (ndatarefs == MAX_DATAREFS) {
+		return NULL;
+	}
+	dataref* d = &datarefs[ndatarefs++];
+	d->name = strdup(name);
+	d->types = types;
+	d->writable = writable;
+	d->live = 1;
+	return d;
+}
+
+XPLMDataRef fake_add_dataref(const char* name, XPLMDataTypeID types, int writable) {
+	XPLMDataRef ref = XPLMFindDataRef(name);
+	if (ref != NULL) {
+		return ref;
+	}
+	return new_dataref(name, types, writable);
+}
+
+void fake_remove_dataref(const char* name) {
+	dataref* d = XPLMFindDataRef(name);
+	if (d != NULL && !d->published) {
+		d->live = 0;
+	}
+}
+
+void fake_set_value(const char* name, double value) {
+	dataref* d = XPLMFindDataRef(name);
+	if (d != NULL && !d->published) {
+		d->value = value;
+	}
+}
+
+XPLMDataRef XPLMFindDataRef(const char* inDataRefName) {
+	for (int i = 0; i < ndatarefs; i++) {
+		if (datarefs[i].live && strcmp(datarefs[i].name, inDataRefName) == 0) {
+			return &datarefs[i];
+		}
+	}
+	return NULL;
+}
+
+int XPLMCanWriteDataRef(XPLMDataRef inDataRef) {
+	return writable_dataref(inDataRef) != NULL;
+}
+
+int XPLMIsDataRefGood(XPLMDataRef inDataRef) {
+	return live_dataref(inDataRef) != NULL;
+}
+
+XPLMDataTypeID XPLMGetDataRefTypes(XPLMDataRef inDataRef) {
+	dataref* d = live_dataref(inDataRef);
+	return d != NULL ? d->types : xplmType_Unknown;
+}
+
+int XPLMGetDatai(XPLMDataRef inDataRef) {
+	dataref* d = live_dataref(inDataRef);
+	if (d == NULL) {
+		return 0;
+	}
+	if (d->published) {
+		return d->geti != NULL ? d->geti(d->readRefcon) : 0;
+	}
+	return (int)d->value;
+}
+
+void XPLMSetDatai(XPLMDataRef inDataRef, int inValue) {
+	dataref* d = writable_dataref(inDataRef);
+	if (d == NULL) {
+		return;
+	}
+	if (d->published) {
+		if (d->seti != NULL) {
+			d->seti(d->writeRefcon, inValue);
+		}
+		return;
+	}
+	d->value = inValue;
+}
+
+float XPLMGetDataf(XPLMDataRef inDataRef) {
+	dataref* d = live_dataref(inDataRef);
+	if (d == NULL) {
+		return 0;
+	}
+	if (d->published) {
+		return d->getf != NULL ? d->getf(d->readRefcon) : 0;
+	}
+	return (float)d->value;
+}
+
+void XPLMSetDataf(XPLMDataRef inDataRef, float inValue) {
+	dataref* d = writable_dataref(inDataRef);
+	if (d == NULL) {
+		return;
+	}
+	if (d->published) {
+		if (d->setf != NULL) {
+			d->setf(d->writeRefcon, inValue);
+		}
+		return;
+	}
+	d->value = inValue;
+}
+
+double XPLMGetDatad(XPLMDataRef inDataRef) {
+	dataref* d = live_dataref(inDataRef);
+	if (d == NULL) {
+		return 0;
+	}
+	if (d->published) {
+		return d->getd != NULL ? d->getd(d->readRefcon) : 0;
+	}
+	return d->value;
+}
+
+void XPLMSetDatad(XPLMDataRef inDataRef, double inValue) {
+	dataref* d = writable_dataref(inDataRef);
+	if (d == NULL) {
+		return;
+	}
+	if (d->published) {
+		if (d->setd != NULL) {
+			d->setd(d->writeRefcon, inValue);
+		}
+		return;
+	}
+	d->value = inValue;
+}
+
+// copy_out implements the array getters: the length without a buffer,
+// otherwise the number of elements copied.
+static int copy_out(void* out, const void* values, int size, int len, int offset, int max) {
+	if (out == NULL) {
+		return len;
+	}
+	if (offset < 0 || offset >= len || max <= 0) {
+		return 0;
+	}
+	int n = len - offset < max ? len - offset : max;
+	memcpy(out, (const char*)values + (size_t)offset * size, (size_t)n * size);
+	return n;
+}
+
+static void copy_in(void* values, const void* in, int size, int len, int offset, int count) {
+	if (in == NULL || offset < 0 || offset >= len || count <= 0) {
+		return;
+	}
+	int n = len - offset < count ? len - offset : count;
+	memcpy((char*)values + (size_t)offset * size, in, (size_t)n * size);
+}
+
+int XPLMGetDatavi(XPLMDataRef inDataRef, int* outValues, int inOffset, int inMax) {
+	dataref* d = live_dataref(inDataRef);
+	if (d == NULL) {
+		return 0;
+	}
+	if (d->published) {
+		return d->getvi != NULL ? d->getvi(d->readRefcon, outValues, inOffset, inMax) : 0;
+	}
+	return copy_out(outValues, d->ints, sizeof(int), MAX_ELEMENTS, inOffset, inMax);
+}
+
+void XPLMSetDatavi(XPLMDataRef inDataRef, int* inValues, int inoffset, int inCount) {
+	dataref* d = writable_dataref(inDataRef);
+	if (d == NULL) {
+		return;
+	}
+	if (d->published) {
+		if (d->setvi != NULL) {
+			d->setvi(d->writeRefcon, inValues, inoffset, inCount);
+		}
+		return;
+	}
+	copy_in(d->ints, inValues, sizeof(int), MAX_ELEMENTS, inoffset, inCount);
+}
+
+int XPLMGetDatavf(XPLMDataRef inDataRef, float* outValues, int inOffset, int inMax) {
+	dataref* d = live_dataref(inDataRef);
+	if (d == NULL) {
+		return 0;
+	}
+	if (d->published) {
+		return d->getvf != NULL ? d->getvf(d->readRefcon, outValues, inOffset, inMax) : 0;
+	}
+	return copy_out(outValues, d->floats, sizeof(float), MAX_ELEMENTS, inOffset, inMax);
+}
+
+void XPLMSetDatavf(XPLMDataRef inDataRef, float* inValues, int inoffset, int inCount) {
+	dataref* d = writable_dataref(inDataRef);
+	if (d == NULL) {
+		return;
+	}
+	if (d->published) {
+		if (d->setvf != NULL) {
+			d->setvf(d->writeRefcon, inValues, inoffset, inCount);
+		}
+		return;
+	}
+	copy_in(d->floats, inValues, sizeof(float), MAX_ELEMENTS, inoffset, inCount);
+}
+
+int XPLMGetDatab(XPLMDataRef inDataRef, void* outValue, int inOffset, int inMaxBytes) {
+	dataref* d = live_dataref(inDataRef);
+	if (d == NULL) {
+		return 0;
+	}
+	if (d->published) {
+		return d->getb != NULL ? d->getb(d->readRefcon, outValue, inOffset, inMaxBytes) : 0;
+	}
+	return copy_out(outValue, d->bytes, 1, MAX_BYTES, inOffset, inMaxBytes);
+}
+
+void XPLMSetDatab(XPLMDataRef inDataRef, void* inValue, int inOffset, int inLength) {
+	dataref* d = writable_dataref(inDataRef);
+	if (d == NULL) {
+		return;
+	}
+	if (d->published) {
+		if (d->setb != NULL) {
+			d->setb(d->writeRefcon, inValue, inOffset, inLength);
+		}
+		return;
+	}
+	copy_in(d->bytes, inValue, 1, MAX_BYTES, inOffset, inLength);
+}
+
+XPLMDataRef XPLMRegisterDataAccessor(const char* inDataName, XPLMDataTypeID inDataType, int inIsWritable,
+	XPLMGetDatai_f inReadInt, XPLMSetDatai_f inWriteInt, XPLMGetDataf_f inReadFloat, XPLMSetDataf_f inWriteFloat,
+	XPLMGetDatad_f inReadDouble, XPLMSetDatad_f inWriteDouble, XPLMGetDatavi_f inReadIntArray, XPLMSetDatavi_f inWriteIntArray,
+	XPLMGetDatavf_f inReadFloatArray, XPLMSetDatavf_f inWriteFloatArray, XPLMGetDatab_f inReadData, XPLMSetDatab_f inWriteData,
+	void* inReadRefcon, void* inWriteRefcon) {
+	dataref* d = new_dataref(inDataName, inDataType, inIsWritable);
+	if (d == NULL) {
+		return NULL;
+	}
+	d->published = 1;
+	d->geti = inReadInt;
+	d->seti = inWriteInt;
+	d->getf = inReadFloat;
+	d->setf = inWriteFloat;
+	d->getd = inReadDouble;
+	d->setd = inWriteDouble;
+	d->getvi = inReadIntArray;
+	d->setvi = inWriteIntArray;
+	d->getvf = inReadFloatArray;
+	d->setvf = inWriteFloatArray;
+	d->getb = inReadData;
+	d->setb = inWriteData;
+	d->readRefcon = inReadRefcon;
+	d->writeRefcon = inWriteRefcon;
+	return d;
+}
+
+void XPLMUnregisterDataAccessor(XPLMDataRef inDataRef) {
+	dataref* d = live_dataref(inDataRef);
+	if (d != NULL && d->published) {
+		d->live = 0;
+	}
+}
+
+// Shared data is created on first use; notifications are not sent.
+int XPLMShareData(const char* inDataName, XPLMDataTypeID inDataType, XPLMDataChanged_f inNotificationFunc, void* inNotificationRefcon) {
+	dataref* d = XPLMFindDataRef(inDataName);
+	if (d == NULL) {
+		return new_dataref(inDataName, inDataType, 1) != NULL;
+	}
+	return d->types == inDataType;
+}
+
+int XPLMUnshareData(const char* inDataName, XPLMDataTypeID inDataType, XPLMDataChanged_f inNotificationFunc, void* inNotificationRefcon) {
+	return XPLMFindDataRef(inDataName) != NULL;
+}
+
+int XPLMCountDataRefs(void) {
+	return ndatarefs;
+}
+
+void XPLMGetDataRefsByIndex(int offset, int count, XPLMDataRef* outDataRefs) {
+	for (int i = 0; i < count; i++) {
+		int index = offset + i;
+		outDataRefs[i] = index >= 0 && index < ndatarefs ? &datarefs[index] : NULL;
+	}
+}
+
+void XPLMGetDataRefInfo(XPLMDataRef inDataRef, XPLMDataRefInfo_t* outInfo) {
+	dataref* d = inDataRef;
+	if (d == NULL) {
+		return;
+	}
+	outInfo->name = d->name;
+	outInfo->type = d->types;
+	outInfo->writable = d->writable;
+	outInfo->owner = d->published ? 1 : XPLM_PLUGIN_XPLANE;
+}
+
+typedef struct {
+	XPLMFlightLoop_f callback;
+	void* refcon;
+	int live;
+	int scheduled;
+	int inSeconds;
+	float dueTime;
+	int dueCycle;
+	float lastCall;
+} flight_loop;
+
+static flight_loop loops[MAX_LOOPS];
+static int nloops;
+static float elapsed, lastFrame;
+static int cycle;
+
+float XPLMGetElapsedTime(void) {
+	return elapsed;
+}
+
+int XPLMGetCycleNumber(void) {
+	return cycle;
+}
+
+XPLMFlightLoopID XPLMCreateFlightLoop(XPLMCreateFlightLoop_t* inParams) {
+	if (nloops == MAX_LOOPS) {
+		return NULL;
+	}
+	flight_loop* l = &loops[nloops++];
+	l->callback = inParams->callbackFunc;
+	l->refcon = inParams->refcon;
+	l->live = 1;
+	l->lastCall = elapsed;
+	return l;
+}
+
+void XPLMDestroyFlightLoop(XPLMFlightLoopID inFlightLoopID) {
+	flight_loop* l = inFlightLoopID;
+	if (l != NULL) {
+		l->live = 0;
+	}
+}
+
+static void schedule(flight_loop* l, float interval, float from) {
+	l->scheduled = interval != 0;
+	l->inSeconds = interval > 0;
+	if (interval > 0) {
+		l->dueTime = from + interval;
+	} else {
+		l->dueCycle = cycle + (int)-interval;
+	}
+}
+
+void XPLMScheduleFlightLoop(XPLMFlightLoopID inFlightLoopID, float inInterval, int inRelativeToNow) {
+	flight_loop* l = inFlightLoopID;
+	if (l != NULL && l->live) {
+		schedule(l, inInterval, inRelativeToNow ? elapsed : l->lastCall);
+	}
+}
+
+// fake_frame advances the clock by dt seconds and calls the flight loops that
+// are due, in creation order. Their return value reschedules them.
+void fake_frame(float dt) {
+	elapsed += dt;
+	cycle++;
+	for (int i = 0; i < nloops; i++) {
+		flight_loop* l = &loops[i];
+		if (!l->live || !l->scheduled || (l->inSeconds ? l->dueTime > elapsed : l->dueCycle > cycle)) {
+			continue;
+		}
+		float since = elapsed - l->lastCall;
+		l->lastCall = elapsed;
+		float next = l->callback(since, elapsed - lastFrame, cycle, l->refcon);
+		if (l->live) {
+			schedule(l, next, elapsed);
+		}
+	}
+	lastFrame = elapsed;
+}
+
+int fake_flight_loops(void) {
+	int n = 0;
+	for (int i = 0; i < nloops; i++) {
+		n += loops[i].live;
+	}
+	return n;
+}
+
+static char* logBuffer;
+static size_t logLen, logCap;
+
+void XPLMDebugString(const char* inString) {
+	size_t n = strlen(inString);
+	if (logLen + n + 1 > logCap) {
+		logCap = (logLen + n + 1) * 2;
+		logBuffer = realloc(logBuffer, logCap);
+	}
+	memcpy(logBuffer + logLen, inString, n + 1);
+	logLen += n;
+}
+
+const char* fake_log(void) {
+	return logBuffer != NULL ? logBuffer : "";
+}
+
+void fake_clear_log(void) {
+	logLen = 0;
+	if (logBuffer != NULL) {
+		logBuffer[0] = 0;
+	}
+}
+
+void XPLMGetSystemPath(char* outSystemPath) {
+	outSystemPath[0] = 0;
+}
+
+void XPLMGetPrefsPath(char* outPrefsPath) {
+	outPrefsPath[0] = 0;
+}
+
+const char* XPLMGetDirectorySeparator(void) {
+	return "/";
+}
+
+XPLMCommandRef XPLMFindCommand(const char* inName) {
+	return NULL;
+}
+
+void XPLMCommandBegin(XPLMCommandRef inCommand) {}
+
+void XPLMCommandEnd(XPLMCommandRef inCommand) {}
+
+void XPLMCommandOnce(XPLMCommandRef inCommand) {}
@@ -0,0 +1,79 @@
+// Package xplmfake defines the XPLM functions used by the library in memory,
+// so that tests and benchmarks link and run outside X-Plane. Test files import
+// it for its side effects:
+//
+//	import _ "github.com/akhenakh/xplane-go/internal/xplmfake"
+//
+// It must never be linked into a plugin, whose XPLM functions are provided by
+// X-Plane. Datarefs hold 64 array elements and 256 bytes, shared data does
+// not notify, and commands do not exist.
+//
+// The fake is not safe for concurrent use: like X-Plane, it expects every call
+// on a single thread.
+package xplmfake
+
+// #cgo CFLAGS: -DXPLM200=1 -DXPLM210=1 -DXPLM400=1 -DXPLM410=1
+// #include <stdlib.h>
+// #include "xplmfake.h"
+import "C"
+
+import "unsafe"
+
+// Data types, as in XPLMDataAccess.h.
+const (
+	TypeInt        = C.xplmType_Int
+	TypeFloat      = C.xplmType_Float
+	TypeDouble     = C.xplmType_Double
+	TypeFloatArray = C.xplmType_FloatArray
+	TypeIntArray   = C.xplmType_IntArray
+	TypeData       = C.xplmType_Data
+)
+
+// AddDataRef creates a dataref owned by the simulator, with a zero value. It
+// does nothing if the dataref already exists.
+func AddDataRef(name string, types int, writable bool) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	w := 0
+	if writable {
+		w = 1
+	}
+	C.fake_add_dataref(cName, C.XPLMDataTypeID(types), C.int(w))
+}
+
+// RemoveDataRef removes a dataref created with AddDataRef, as if the simulator
+// did not define it.
+func RemoveDataRef(name string) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	C.fake_remove_dataref(cName)
+}
+
+// SetValue sets a scalar dataref created with AddDataRef, even a read-only
+// one, as the simulator would.
+func SetValue(name string, value float64) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	C.fake_set_value(cName, C.double(value))
+}
+
+// Frame advances the elapsed time by dt seconds and calls the flight loops
+// that are due, like a simulator frame.
+func Frame(dt float32) {
+	C.fake_frame(C.float(dt))
+}
+
+// FlightLoops returns the number of flight loops created and not destroyed.
+func FlightLoops() int {
+	return int(C.fake_flight_loops())
+}
+
+// Log returns what was written with XPLMDebugString since the last ClearLog.
+func Log() string {
+	return C.GoString(C.fake_log())
+}
+
+// ClearLog empties the log.
+func ClearLog() {
+	C.fake_clear_log()
+}
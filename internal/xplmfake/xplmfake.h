@@ -0,0 +1,16 @@
+#ifndef XPLMFAKE_H
+#define XPLMFAKE_H
+
+#include "XPLMDataAccess.h"
+#include "XPLMProcessing.h"
+#include "XPLMUtilities.h"
+
+XPLMDataRef fake_add_dataref(const char* name, XPLMDataTypeID types, int writable);
+void fake_remove_dataref(const char* name);
+void fake_set_value(const char* name, double value);
+void fake_frame(float dt);
+int fake_flight_loops(void);
+const char* fake_log(void);
+void fake_clear_log(void);
+
+#endif
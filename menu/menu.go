@@ -10,6 +10,8 @@ import "C"
 import (
 	"sync"
 	"unsafe"
+
+	"github.com/akhenakh/xplane-go/util"
 )
 
 type MenuID C.XPLMMenuID
@@ -125,6 +127,9 @@ func menuHandler_cgo(inMenuRef, inItemRef unsafe.Pointer) {
 	itemID := uintptr(inItemRef)
 	actualItemRef := getItemRef(itemID)
 
+	// A panicking handler is removed; its menu items do nothing afterwards.
+	defer util.RecoverPanic("menu handler", func() { unregisterHandler(handlerID) })
+
 	// Pass the correct, original menuRef to the user's handler.
 	handler(actualMenuRef, actualItemRef)
 }
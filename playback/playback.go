@@ -0,0 +1,404 @@
+// Package playback replays a recorded flightlog into X-Plane, writing the
+// recorded datarefs every frame from a flight loop.
+//
+// To move the aircraft, the log should contain the writable position and
+// attitude datarefs, e.g. sim/flightmodel/position/local_x, local_y, local_z,
+// psi, theta and phi. Read-only datarefs such as latitude are skipped.
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/flightlog"
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/processing"
+)
+
+var (
+	ErrPlaying     = errors.New("playback: already playing")
+	ErrNoRecords   = errors.New("playback: log has no records")
+	ErrNoTargets   = errors.New("playback: no writable dataref in log")
+	ErrNotPlaying  = errors.New("playback: not playing")
+	ErrInvalidRate = errors.New("playback: time scale must be positive")
+)
+
+// DefaultOverrides are set while playing so that the flight model and the
+// joystick do not fight the replay.
+var DefaultOverrides = []string{
+	"sim/operation/override/override_planepath",
+	"sim/operation/override/override_joystick",
+}
+
+// DefaultAngles are datarefs holding headings or bank angles in degrees,
+// which are interpolated the short way around the circle and replayed in
+// [0, 360).
+var DefaultAngles = []string{
+	"sim/flightmodel/position/psi",
+	"sim/flightmodel/position/phi",
+	"sim/flightmodel/position/true_psi",
+	"sim/flightmodel/position/mag_psi",
+	"sim/flightmodel/position/hpath",
+}
+
+// Options tune a Player. The zero value replays every writable dataref with
+// DefaultOverrides and DefaultAngles.
+type Options struct {
+	Overrides []string // Override datarefs set to 1 while playing; nil means DefaultOverrides.
+	Angles    []string // Datarefs interpolated as angles in degrees; nil means DefaultAngles.
+	Skip      []string // Recorded datarefs not to replay.
+	Loop      bool     // Start over at the end instead of pausing.
+	Phase     processing.FlightLoopPhase
+	OnEnd     func() // Called on the simulator thread when the end is reached without Loop.
+}
+
+// target is a recorded channel replayed into a dataref.
+type target struct {
+	name   string
+	ref    dref.DataRef
+	types  dref.DataType
+	first  int // Index of the channel's first value in a record.
+	count  int
+	step   bool // Integer values are not interpolated.
+	angle  bool
+	ints   []int32
+	floats []float32
+	bytes  []byte
+}
+
+// Player replays a log. Its controls may be called from any goroutine; the
+// datarefs are written on the simulator thread.
+type Player struct {
+	header  flightlog.Header
+	records []flightlog.Record
+	targets []*target
+	skipped []string
+	options Options
+
+	mutex     sync.Mutex
+	loop      *processing.FlightLoop
+	overrides *dref.OverrideManager
+	position  float64 // Seconds into the log.
+	scale     float64
+	paused    bool
+	index     int // Last record at or before position.
+	values    []float64
+}
+
+var (
+	active      = make(map[*Player]bool)
+	activeMutex sync.Mutex
+)
+
+func init() {
+	// Stop writing datarefs once the overrides are gone.
+	plugin.OnDisable(func() {
+		activeMutex.Lock()
+		players := make([]*Player, 0, len(active))
+		for p := range active {
+			players = append(players, p)
+		}
+		activeMutex.Unlock()
+		for _, p := range players {
+			p.Stop()
+		}
+	})
+}
+
+// Load reads a binary flightlog or, if the file is not one, a CSV export.
+func Load(path string, options Options) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("playback: %w", err)
+	}
+	defer f.Close()
+
+	r, err := flightlog.NewReader(f)
+	if errors.Is(err, flightlog.ErrBadMagic) {
+		if _, err := f.Seek(0, 0); err != nil {
+			return nil, fmt.Errorf("playback: %w", err)
+		}
+		header, records, err := flightlog.ReadCSV(f)
+		if err != nil {
+			return nil, fmt.Errorf("playback: %w", err)
+		}
+		return New(header, records, options)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("playback: %w", err)
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("playback: %w", err)
+	}
+	return New(r.Header(), records, options)
+}
+
+// New prepares the replay of records. Recorded datarefs that do not exist or
+// are not writable are skipped and reported by Skipped.
+func New(header flightlog.Header, records []flightlog.Record, options Options) (*Player, error) {
+	if len(records) == 0 {
+		return nil, ErrNoRecords
+	}
+	if options.Overrides == nil {
+		options.Overrides = DefaultOverrides
+	}
+	if options.Angles == nil {
+		options.Angles = DefaultAngles
+	}
+	p := &Player{header: header, records: records, options: options, scale: 1}
+
+	first := 0
+	for _, c := range header.Channels {
+		t := &target{
+			name:  c.Name,
+			first: first,
+			count: c.Count,
+			step:  c.Type == flightlog.Int32 || c.Type == flightlog.Byte,
+			angle: slices.Contains(options.Angles, c.Name),
+		}
+		first += c.Count
+		if slices.Contains(options.Skip, c.Name) {
+			continue
+		}
+		ref, err := dref.FindDataRef(c.Name)
+		if err != nil || !dref.CanWriteDataRef(ref) {
+			p.skipped = append(p.skipped, c.Name)
+			continue
+		}
+		t.ref, t.types = ref, dref.GetDataRefTypes(ref)
+		switch {
+		case c.Count == 1 && t.types&(dref.TypeInt|dref.TypeFloat|dref.TypeDouble) != 0:
+		case t.types&dref.TypeFloatArray != 0:
+			t.floats = make([]float32, c.Count)
+		case t.types&dref.TypeIntArray != 0:
+			t.ints = make([]int32, c.Count)
+		case t.types&dref.TypeData != 0:
+			t.bytes = make([]byte, c.Count)
+		default:
+			p.skipped = append(p.skipped, c.Name)
+			continue
+		}
+		p.targets = append(p.targets, t)
+	}
+	if len(p.targets) == 0 {
+		return nil, ErrNoTargets
+	}
+	p.values = make([]float64, header.Width())
+	return p, nil
+}
+
+// Skipped returns the recorded datarefs that cannot be replayed.
+func (p *Player) Skipped() []string {
+	return p.skipped
+}
+
+// Duration returns the length of the log in seconds.
+func (p *Player) Duration() float64 {
+	return p.records[len(p.records)-1].Time - p.records[0].Time
+}
+
+// Play sets the overrides and starts writing datarefs from the current
+// position, or from the start if the end was reached.
+func (p *Player) Play() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.loop != nil {
+		return ErrPlaying
+	}
+
+	p.overrides = dref.NewOverrideManager()
+	for _, name := range p.options.Overrides {
+		if err := p.overrides.Override(name); err != nil {
+			p.overrides.Close()
+			p.overrides = nil
+			return fmt.Errorf("playback: %w", err)
+		}
+	}
+	if p.position >= p.Duration() {
+		p.position = 0
+	}
+	p.paused = false
+	p.loop = processing.NewFlightLoop(p.options.Phase, p.frame)
+	p.loop.Schedule(-1, true)
+
+	activeMutex.Lock()
+	active[p] = true
+	activeMutex.Unlock()
+	return nil
+}
+
+// Stop stops writing datarefs and restores the overrides, handing the
+// aircraft back to the flight model. The position is kept, so Play resumes
+// where the replay stopped.
+func (p *Player) Stop() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.loop == nil {
+		return ErrNotPlaying
+	}
+	activeMutex.Lock()
+	delete(active, p)
+	activeMutex.Unlock()
+
+	p.loop.Destroy()
+	p.loop = nil
+	p.overrides.Close()
+	p.overrides = nil
+	return nil
+}
+
+// Playing reports whether the player is between Play and Stop.
+func (p *Player) Playing() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.loop != nil
+}
+
+// Pause freezes the replay on the current sample. The overrides stay set.
+func (p *Player) Pause() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.paused = true
+}
+
+// Resume continues a paused replay.
+func (p *Player) Resume() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.paused = false
+}
+
+// Paused reports whether the replay is paused.
+func (p *Player) Paused() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.paused
+}
+
+// SetTimeScale changes the replay speed: 1 is real time, 2 twice as fast.
+func (p *Player) SetTimeScale(scale float64) error {
+	if scale <= 0 || math.IsInf(scale, 0) || math.IsNaN(scale) {
+		return ErrInvalidRate
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.scale = scale
+	return nil
+}
+
+// Seek moves the replay to t seconds from the start of the log, clamped to
+// the log's duration.
+func (p *Player) Seek(t float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.position = max(0, min(t, p.Duration()))
+}
+
+// Position returns the current replay time in seconds from the start of the
+// log.
+func (p *Player) Position() float64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.position
+}
+
+func (p *Player) frame(elapsedSinceLastCall, _ float32, _ int) float32 {
+	p.mutex.Lock()
+	var onEnd func()
+	if !p.paused {
+		p.position += float64(elapsedSinceLastCall) * p.scale
+		if end := p.Duration(); p.position >= end {
+			if p.options.Loop {
+				p.position = math.Mod(p.position, max(end, 1e-6))
+			} else {
+				p.position, p.paused = end, true
+				onEnd = p.options.OnEnd
+			}
+		}
+	}
+	p.sample()
+	p.write()
+	p.mutex.Unlock()
+
+	if onEnd != nil {
+		onEnd()
+	}
+	return -1
+}
+
+// sample interpolates every value at the current position into p.values.
+func (p *Player) sample() {
+	t := p.records[0].Time + p.position
+	// Most frames stay between the same two records, so only search the
+	// log when the position left them.
+	i := p.index
+	if i >= len(p.records) || p.records[i].Time > t || (i+1 < len(p.records) && p.records[i+1].Time <= t) {
+		i = max(sort.Search(len(p.records), func(j int) bool { return p.records[j].Time > t })-1, 0)
+		p.index = i
+	}
+
+	a := p.records[i]
+	if i+1 >= len(p.records) {
+		copy(p.values, a.Values)
+		return
+	}
+	b := p.records[i+1]
+	frac := 0.0
+	if dt := b.Time - a.Time; dt > 0 {
+		frac = (t - a.Time) / dt
+	}
+	for _, tg := range p.targets {
+		for k := tg.first; k < tg.first+tg.count; k++ {
+			switch {
+			case tg.step:
+				p.values[k] = a.Values[k]
+			case tg.angle:
+				d := math.Mod(b.Values[k]-a.Values[k]+540, 360) - 180
+				v := math.Mod(a.Values[k]+d*frac, 360)
+				if v < 0 {
+					v += 360
+				}
+				p.values[k] = v
+			default:
+				p.values[k] = a.Values[k] + (b.Values[k]-a.Values[k])*frac
+			}
+		}
+	}
+}
+
+// write sends p.values to the target datarefs.
+func (p *Player) write() {
+	for _, tg := range p.targets {
+		values := p.values[tg.first : tg.first+tg.count]
+		switch {
+		case tg.floats != nil:
+			for i, v := range values {
+				tg.floats[i] = float32(v)
+			}
+			dref.SetFloatArray(tg.ref, tg.floats, 0)
+		case tg.ints != nil:
+			for i, v := range values {
+				tg.ints[i] = int32(math.Round(v))
+			}
+			dref.SetIntArray(tg.ref, tg.ints, 0)
+		case tg.bytes != nil:
+			for i, v := range values {
+				tg.bytes[i] = byte(v)
+			}
+			dref.SetBytes(tg.ref, tg.bytes)
+		case tg.types&dref.TypeDouble != 0:
+			dref.SetDouble(tg.ref, values[0])
+		case tg.types&dref.TypeFloat != 0:
+			dref.SetFloat(tg.ref, float32(values[0]))
+		default:
+			dref.SetInt(tg.ref, int(math.Round(values[0])))
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package playback
+
+import (
+	"math"
+	"testing"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/flightlog"
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+)
+
+var testHeader = flightlog.Header{
+	Rate: 1,
+	Channels: []flightlog.Channel{
+		{Name: "sim/flightmodel/position/psi", Type: flightlog.Float32, Count: 1},
+		{Name: "xplane-go/test/playback/altitude", Type: flightlog.Float64, Count: 1},
+		{Name: "xplane-go/test/playback/gear", Type: flightlog.Int32, Count: 1},
+		{Name: "xplane-go/test/playback/missing", Type: flightlog.Float32, Count: 1},
+	},
+}
+
+var testRecords = []flightlog.Record{
+	{Time: 10, Values: []float64{350, 100, 0, 1}},
+	{Time: 11, Values: []float64{10, 200, 1, 2}},
+	{Time: 13, Values: []float64{20, 300, 1, 3}},
+}
+
+func newPlayer(t *testing.T, options Options) *Player {
+	t.Helper()
+	xplmfake.AddDataRef("sim/flightmodel/position/psi", xplmfake.TypeFloat, true)
+	xplmfake.AddDataRef("xplane-go/test/playback/altitude", xplmfake.TypeDouble, true)
+	xplmfake.AddDataRef("xplane-go/test/playback/gear", xplmfake.TypeInt, true)
+	for _, name := range DefaultOverrides {
+		xplmfake.AddDataRef(name, xplmfake.TypeInt, true)
+	}
+	p, err := New(testHeader, testRecords, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// values returns the replayed psi, altitude and gear.
+func values(t *testing.T) [3]float64 {
+	t.Helper()
+	var v [3]float64
+	for i, name := range []string{"sim/flightmodel/position/psi", "xplane-go/test/playback/altitude", "xplane-go/test/playback/gear"} {
+		ref, err := dref.FindDataRef(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch i {
+		case 0:
+			v[i] = float64(dref.GetFloat(ref))
+		case 1:
+			v[i] = dref.GetDouble(ref)
+		default:
+			v[i] = float64(dref.GetInt(ref))
+		}
+	}
+	return v
+}
+
+func checkValues(t *testing.T, at string, want [3]float64) {
+	t.Helper()
+	got := values(t)
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-3 {
+			t.Errorf("at %s: psi, altitude, gear = %v, want %v", at, got, want)
+			return
+		}
+	}
+}
+
+func TestPlayback(t *testing.T) {
+	p := newPlayer(t, Options{})
+	if got := p.Skipped(); len(got) != 1 || got[0] != "xplane-go/test/playback/missing" {
+		t.Errorf("Skipped() = %v", got)
+	}
+	if d := p.Duration(); d != 3 {
+		t.Errorf("Duration() = %v, want 3", d)
+	}
+	if err := p.Play(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	override, _ := dref.FindDataRef(DefaultOverrides[0])
+	if dref.GetInt(override) != 1 {
+		t.Error("override not set while playing")
+	}
+
+	// psi goes the short way from 350 to 10, and wraps at 360.
+	xplmfake.Frame(0.25)
+	checkValues(t, "0.25s", [3]float64{355, 125, 0})
+	xplmfake.Frame(0.5)
+	checkValues(t, "0.75s", [3]float64{5, 175, 0})
+	xplmfake.Frame(1.25)
+	checkValues(t, "2s", [3]float64{15, 250, 1})
+
+	p.Seek(0.5)
+	xplmfake.Frame(0)
+	checkValues(t, "seek to 0.5s", [3]float64{0, 150, 0})
+	p.Seek(-1)
+	xplmfake.Frame(0)
+	checkValues(t, "seek before the start", [3]float64{350, 100, 0})
+
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if dref.GetInt(override) != 0 {
+		t.Error("override not restored by Stop")
+	}
+	if err := p.Stop(); err != ErrNotPlaying {
+		t.Errorf("second Stop: err = %v, want ErrNotPlaying", err)
+	}
+}
+
+func TestPlaybackEnd(t *testing.T) {
+	ended := 0
+	p := newPlayer(t, Options{OnEnd: func() { ended++ }})
+	if err := p.SetTimeScale(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Play(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	xplmfake.Frame(1)
+	checkValues(t, "1s at twice the speed", [3]float64{15, 250, 1})
+	xplmfake.Frame(1)
+	xplmfake.Frame(1)
+	checkValues(t, "the end", [3]float64{20, 300, 1})
+	if ended != 1 || !p.Paused() || p.Position() != 3 {
+		t.Errorf("OnEnd called %d times, paused %t at %v", ended, p.Paused(), p.Position())
+	}
+}
+
+func TestAngleWrap(t *testing.T) {
+	p := newPlayer(t, Options{})
+	for _, tc := range []struct {
+		a, b, frac, want float64
+	}{
+		{350, 10, 0.5, 0},
+		{350, 10, 0.75, 5},
+		{10, 350, 0.75, 355},
+		{-170, 170, 0.5, 180},
+		{90, 180, 0.5, 135},
+	} {
+		p.records = []flightlog.Record{
+			{Time: 0, Values: []float64{tc.a, 0, 0, 0}},
+			{Time: 1, Values: []float64{tc.b, 0, 0, 0}},
+		}
+		p.index, p.position = 0, tc.frac
+		p.sample()
+		if got := p.values[0]; math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%v to %v at %v = %v, want %v", tc.a, tc.b, tc.frac, got, tc.want)
+		}
+	}
+}
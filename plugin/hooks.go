@@ -0,0 +1,42 @@
+package plugin
+
+import "sync"
+
+var (
+	disableHooks []func()
+	stopHooks    []func()
+	hooksMutex   sync.Mutex
+)
+
+// OnDisable registers a function that is called every time X-Plane disables
+// the plugin, after the plugin's own Disable method has returned.
+// Library packages use it to release SDK resources they own on behalf of the plugin.
+// Hooks run in reverse registration order, like deferred calls.
+func OnDisable(fn func()) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	disableHooks = append(disableHooks, fn)
+}
+
+// OnStop registers a function that is called when X-Plane unloads the plugin,
+// after the plugin's own Stop method has returned.
+// Hooks run in reverse registration order, like deferred calls.
+func OnStop(fn func()) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	stopHooks = append(stopHooks, fn)
+}
+
+// runHooks calls every hook in reverse order. The slice is copied so hooks
+// may safely register further hooks. A panicking hook does not prevent the
+// others from running.
+func runHooks(hooks *[]func()) {
+	hooksMutex.Lock()
+	pending := make([]func(), len(*hooks))
+	copy(pending, *hooks)
+	hooksMutex.Unlock()
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		call("plugin hook", pending[i])
+	}
+}
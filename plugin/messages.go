@@ -0,0 +1,21 @@
+package plugin
+
+// #cgo CFLAGS: -DXPLM200=1 -DXPLM210=1 -DXPLM301=1 -DXPLM303=1 -DXPLM400=1
+// #include "XPLMPlugin.h"
+import "C"
+
+// Messages X-Plane broadcasts to every plugin through MessageHandler.
+const (
+	MsgPlaneCrashed         Message = C.XPLM_MSG_PLANE_CRASHED
+	MsgPlaneLoaded          Message = C.XPLM_MSG_PLANE_LOADED
+	MsgAirportLoaded        Message = C.XPLM_MSG_AIRPORT_LOADED
+	MsgSceneryLoaded        Message = C.XPLM_MSG_SCENERY_LOADED
+	MsgAirplaneCountChanged Message = C.XPLM_MSG_AIRPLANE_COUNT_CHANGED
+	MsgPlaneUnloaded        Message = C.XPLM_MSG_PLANE_UNLOADED
+	MsgWillWritePrefs       Message = C.XPLM_MSG_WILL_WRITE_PREFS
+	MsgLiveryLoaded         Message = C.XPLM_MSG_LIVERY_LOADED
+	MsgEnteredVR            Message = C.XPLM_MSG_ENTERED_VR
+	MsgExitingVR            Message = C.XPLM_MSG_EXITING_VR
+	MsgReleasePlanes        Message = C.XPLM_MSG_RELEASE_PLANES
+	MsgDataRefsAdded        Message = C.XPLM_MSG_DATAREFS_ADDED
+)
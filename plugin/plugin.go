@@ -63,6 +63,12 @@ func XPluginStart(outName, outSig, outDesc *C.char) C.int {
 		return 0
 	}
 
+	// Returning 0 makes X-Plane unload the plugin instead of crashing.
+	defer util.RecoverPanic("plugin Start", func() {
+		C.strncpy(outName, C.CString("Error"), 255)
+		C.strncpy(outSig, C.CString("xplane-go.error.start"), 255)
+		C.strncpy(outDesc, C.CString("xplane-go: plugin start panicked"), 255)
+	})
 	name, sig, desc, err := pluginImpl.Start()
 	if err != nil {
 		errMsg := "xplane-go: plugin start failed: " + err.Error() + "\n"
@@ -91,12 +97,15 @@ func XPluginStart(outName, outSig, outDesc *C.char) C.int {
 //export XPluginStop
 func XPluginStop() {
 	if pluginImpl != nil {
-		pluginImpl.Stop()
+		call("plugin Stop", pluginImpl.Stop)
 	}
+	runHooks(&stopHooks)
 }
 
 //export XPluginEnable
 func XPluginEnable() C.int {
+	// Returning 0 leaves the plugin disabled.
+	defer util.RecoverPanic("plugin Enable", nil)
 	if pluginImpl != nil {
 		if err := pluginImpl.Enable(); err != nil {
 			util.DebugString("xplane-go: plugin enable failed: " + err.Error() + "\n")
@@ -110,13 +119,22 @@ func XPluginEnable() C.int {
 //export XPluginDisable
 func XPluginDisable() {
 	if pluginImpl != nil {
-		pluginImpl.Disable()
+		call("plugin Disable", pluginImpl.Disable)
 	}
+	runHooks(&disableHooks)
 }
 
 //export XPluginReceiveMessage
 func XPluginReceiveMessage(inFrom C.XPLMPluginID, inMsg C.int, inParam unsafe.Pointer) {
+	defer util.RecoverPanic("plugin ReceiveMessage", nil)
 	if handler, ok := pluginImpl.(MessageHandler); ok {
 		handler.ReceiveMessage(PluginID(inFrom), Message(inMsg), inParam)
 	}
 }
+
+// call runs fn, recovering a panic so that the hooks registered by the
+// library still release their resources.
+func call(callback string, fn func()) {
+	defer util.RecoverPanic(callback, nil)
+	fn()
+}
@@ -0,0 +1,206 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/util"
+)
+
+const (
+	// DefaultQueueSize is the number of functions StartDispatcher queues
+	// when given a size <= 0.
+	DefaultQueueSize = 1024
+	// DefaultFrameBudget is the time the dispatcher spends per frame running
+	// queued functions.
+	DefaultFrameBudget = 2 * time.Millisecond
+)
+
+var (
+	ErrQueueFull         = errors.New("main thread queue is full")
+	ErrDispatcherStopped = errors.New("main thread dispatcher is not running")
+)
+
+// task is a queued function. abort, if set, is called with the reason when
+// the dispatcher stops before running it, or when run panics.
+type task struct {
+	run   func()
+	abort func(error)
+}
+
+// call runs the task, recovering a panic so that the queue keeps draining.
+func (t task) call() {
+	defer util.RecoverPanic("main thread function", func() {
+		if t.abort != nil {
+			t.abort(util.ErrPanicked)
+		}
+	})
+	t.run()
+}
+
+var (
+	dispatchQueue chan task
+	dispatchLoop  *FlightLoop
+	dispatchMutex sync.RWMutex
+	frameBudget   atomic.Int64
+)
+
+func init() {
+	frameBudget.Store(int64(DefaultFrameBudget))
+	plugin.OnDisable(StopDispatcher)
+}
+
+// StartDispatcher starts the flight loop running the functions queued by
+// RunOnMainThread and CallOnMainThread, with room for queueSize of them. It
+// must be called on the simulator thread, e.g. from the plugin's Enable. It is
+// stopped automatically when the plugin is disabled.
+func StartDispatcher(queueSize int) {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	dispatchMutex.Lock()
+	defer dispatchMutex.Unlock()
+	if dispatchLoop != nil {
+		return
+	}
+	dispatchQueue = make(chan task, queueSize)
+	dispatchLoop = NewFlightLoop(BeforeFlightModel, dispatch)
+	dispatchLoop.Schedule(-1, true)
+}
+
+// StopDispatcher destroys the dispatcher's flight loop. Functions still queued
+// are dropped, and their futures fail with ErrDispatcherStopped.
+func StopDispatcher() {
+	dispatchMutex.Lock()
+	if dispatchLoop == nil {
+		dispatchMutex.Unlock()
+		return
+	}
+	dispatchLoop.Destroy()
+	dispatchLoop = nil
+	queue := dispatchQueue
+	dispatchQueue = nil
+	dispatchMutex.Unlock()
+
+	for {
+		select {
+		case t := <-queue:
+			if t.abort != nil {
+				t.abort(ErrDispatcherStopped)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// SetFrameBudget sets the time the dispatcher spends per frame running queued
+// functions. At least one function runs per frame, so a slow function delays
+// the rest to the next frame rather than stalling the simulator further.
+func SetFrameBudget(budget time.Duration) {
+	frameBudget.Store(int64(budget))
+}
+
+// RunOnMainThread queues fn to run on the simulator thread during the next
+// frames. It never blocks: it fails with ErrQueueFull when the queue is full,
+// and with ErrDispatcherStopped when the dispatcher is not running. Called
+// from the simulator thread, fn still runs later, not immediately.
+func RunOnMainThread(fn func()) error {
+	return enqueue(task{run: fn})
+}
+
+func enqueue(t task) error {
+	dispatchMutex.RLock()
+	defer dispatchMutex.RUnlock()
+	if dispatchQueue == nil {
+		return ErrDispatcherStopped
+	}
+	select {
+	case dispatchQueue <- t:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// dispatch runs queued functions until the frame budget is spent.
+func dispatch(_, _ float32, _ int) float32 {
+	dispatchMutex.RLock()
+	queue := dispatchQueue
+	dispatchMutex.RUnlock()
+
+	budget := time.Duration(frameBudget.Load())
+	start := time.Now()
+	for {
+		select {
+		case t := <-queue:
+			t.call()
+		default:
+			return -1
+		}
+		if time.Since(start) >= budget {
+			return -1
+		}
+	}
+}
+
+// Future is the result of a function run on the simulator thread.
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// CallOnMainThread queues fn like RunOnMainThread and returns a future for its
+// result. If fn cannot be queued, the future fails right away; if it panics,
+// the future fails with util.ErrPanicked.
+func CallOnMainThread[T any](fn func() (T, error)) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+	err := enqueue(task{
+		run: func() {
+			f.value, f.err = fn()
+			close(f.done)
+		},
+		abort: func(err error) {
+			f.err = err
+			close(f.done)
+		},
+	})
+	if err != nil {
+		f.err = err
+		close(f.done)
+	}
+	return f
+}
+
+// Done returns a channel closed when the result is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the result, or until ctx is done. Do not call it from the
+// simulator thread, which would wait for itself.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Ready reports whether the result is available, so that it can be polled
+// from the simulator thread before calling Wait.
+func (f *Future[T]) Ready() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
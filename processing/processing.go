@@ -8,8 +8,13 @@ package processing
 import "C"
 
 import (
+	"fmt"
+	"runtime"
+	"sort"
 	"sync"
 	"unsafe"
+
+	"github.com/akhenakh/xplane-go/util"
 )
 
 // FlightLoopID is an opaque handle to a registered flight loop.
@@ -19,28 +24,25 @@ type FlightLoopID C.XPLMFlightLoopID
 // It returns the delay in seconds for the next callback. Return 0 to un-schedule.
 type FlightLoopCallback func(elapsedSinceLastCall, elapsedTimeSinceLastFlightLoop float32, counter int) float32
 
+// FlightLoop owns a flight loop's SDK handle and its Go callback, so that
+// destroying it releases both.
+type FlightLoop struct {
+	handle   FlightLoopID
+	refcon   uintptr
+	phase    FlightLoopPhase
+	callback FlightLoopCallback
+	creator  string // file:line of the NewFlightLoop caller, for leak reports.
+	failed   bool   // Set when the callback panicked; only used on the simulator thread.
+}
+
 var (
-	registry      = make(map[uintptr]FlightLoopCallback)
+	registry      = make(map[uintptr]*FlightLoop)
+	byHandle      = make(map[FlightLoopID]*FlightLoop)
 	registryMutex sync.RWMutex
 	nextID        uintptr = 1
 )
 
-func registerCallback(callback FlightLoopCallback) uintptr {
-	registryMutex.Lock()
-	defer registryMutex.Unlock()
-	id := nextID
-	nextID++
-	registry[id] = callback
-	return id
-}
-
-func unregisterCallback(id uintptr) {
-	registryMutex.Lock()
-	defer registryMutex.Unlock()
-	delete(registry, id)
-}
-
-func getCallback(id uintptr) FlightLoopCallback {
+func getLoop(id uintptr) *FlightLoop {
 	registryMutex.RLock()
 	defer registryMutex.RUnlock()
 	return registry[id]
@@ -49,15 +51,21 @@ func getCallback(id uintptr) FlightLoopCallback {
 //export flightLoopCallback_cgo
 func flightLoopCallback_cgo(inElapsedSinceLastCall, inElapsedTimeSinceLastFlightLoop C.float, inCounter C.int, inRefcon unsafe.Pointer) C.float {
 	id := uintptr(inRefcon)
-	if callback := getCallback(id); callback != nil {
-		nextInterval := callback(
-			float32(inElapsedSinceLastCall),
-			float32(inElapsedTimeSinceLastFlightLoop),
-			int(inCounter),
-		)
-		return C.float(nextInterval)
+	loop := getLoop(id)
+	if loop == nil || loop.failed {
+		return 0
 	}
-	return 0
+	// Returning 0 unschedules the loop; it is not called again until destroyed.
+	defer util.RecoverPanic("flight loop", func() {
+		loop.failed = true
+		util.DebugString(fmt.Sprintf("xplane-go: %s disabled\n", loop))
+	})
+	nextInterval := loop.callback(
+		float32(inElapsedSinceLastCall),
+		float32(inElapsedTimeSinceLastFlightLoop),
+		int(inCounter),
+	)
+	return C.float(nextInterval)
 }
 
 type FlightLoopPhase int
@@ -67,30 +75,130 @@ const (
 	AfterFlightModel  FlightLoopPhase = C.xplm_FlightLoop_Phase_AfterFlightModel
 )
 
-// CreateFlightLoop registers a new flight loop and returns its ID.
-// The flight loop is initially unscheduled. Use ScheduleFlightLoop to start it.
-func CreateFlightLoop(phase FlightLoopPhase, callback FlightLoopCallback) FlightLoopID {
-	id := registerCallback(callback)
-	refcon := unsafe.Pointer(id)
+// NewFlightLoop registers a new flight loop. It is initially unscheduled;
+// use Schedule to start it and Destroy to release it.
+func NewFlightLoop(phase FlightLoopPhase, callback FlightLoopCallback) *FlightLoop {
+	return newFlightLoop(phase, callback, 2)
+}
+
+func newFlightLoop(phase FlightLoopPhase, callback FlightLoopCallback, skip int) *FlightLoop {
+	loop := &FlightLoop{phase: phase, callback: callback, creator: "unknown"}
+	if _, file, line, ok := runtime.Caller(skip); ok {
+		loop.creator = fmt.Sprintf("%s:%d", file, line)
+	}
+
+	registryMutex.Lock()
+	loop.refcon = nextID
+	nextID++
+	registry[loop.refcon] = loop
+	registryMutex.Unlock()
+
 	params := C.XPLMCreateFlightLoop_t{
 		structSize:   C.int(unsafe.Sizeof(C.XPLMCreateFlightLoop_t{})),
 		phase:        C.XPLMFlightLoopPhaseType(phase),
 		callbackFunc: (C.XPLMFlightLoop_f)(C.flightLoopCallback_cgo),
-		refcon:       refcon,
+		refcon:       unsafe.Pointer(loop.refcon),
 	}
-	// Pass the address of the params struct using &params
-	return FlightLoopID(C.XPLMCreateFlightLoop(&params))
+	loop.handle = FlightLoopID(C.XPLMCreateFlightLoop(&params))
+
+	registryMutex.Lock()
+	byHandle[loop.handle] = loop
+	registryMutex.Unlock()
+	return loop
 }
 
-// DestroyFlightLoop unregisters a flight loop and removes it from the Go registry.
+// ID returns the SDK handle of the flight loop, or nil once destroyed.
+func (l *FlightLoop) ID() FlightLoopID {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	return l.handle
+}
+
+// Phase returns the phase the flight loop runs in.
+func (l *FlightLoop) Phase() FlightLoopPhase {
+	return l.phase
+}
+
+// String describes the flight loop by where it was created.
+func (l *FlightLoop) String() string {
+	return "flight loop created at " + l.creator
+}
+
+// Schedule (re-)schedules the flight loop:
+//   - interval > 0: seconds from now.
+//   - interval < 0: flight loops from now.
+//   - interval = 0: unschedule the callback.
+//
+// It does nothing once the flight loop is destroyed.
+func (l *FlightLoop) Schedule(interval float32, relativeToNow bool) {
+	handle := l.ID()
+	if handle == nil {
+		return
+	}
+	rel := 0
+	if relativeToNow {
+		rel = 1
+	}
+	C.XPLMScheduleFlightLoop(C.XPLMFlightLoopID(handle), C.float(interval), C.int(rel))
+}
+
+// Unschedule stops calling the flight loop until it is scheduled again.
+func (l *FlightLoop) Unschedule() {
+	l.Schedule(0, true)
+}
+
+// Destroy unregisters the flight loop and releases its callback. Calling it
+// again does nothing.
+func (l *FlightLoop) Destroy() {
+	registryMutex.Lock()
+	handle := l.handle
+	if handle == nil {
+		registryMutex.Unlock()
+		return
+	}
+	l.handle = nil
+	delete(registry, l.refcon)
+	delete(byHandle, handle)
+	registryMutex.Unlock()
+
+	C.XPLMDestroyFlightLoop(C.XPLMFlightLoopID(handle))
+}
+
+// Destroyed reports whether Destroy was called.
+func (l *FlightLoop) Destroyed() bool {
+	return l.ID() == nil
+}
+
+// LiveFlightLoops returns the flight loops that were created and not yet
+// destroyed, oldest first. A plugin can check it is empty after Stop to find
+// leaked loops, which String describes by where they were created.
+func LiveFlightLoops() []*FlightLoop {
+	registryMutex.RLock()
+	loops := make([]*FlightLoop, 0, len(registry))
+	for _, loop := range registry {
+		loops = append(loops, loop)
+	}
+	registryMutex.RUnlock()
+	sort.Slice(loops, func(i, j int) bool { return loops[i].refcon < loops[j].refcon })
+	return loops
+}
+
+// CreateFlightLoop registers a new flight loop and returns its ID.
+// The flight loop is initially unscheduled. Use ScheduleFlightLoop to start it.
+// NewFlightLoop returns a FlightLoop object instead.
+func CreateFlightLoop(phase FlightLoopPhase, callback FlightLoopCallback) FlightLoopID {
+	return newFlightLoop(phase, callback, 2).handle
+}
+
+// DestroyFlightLoop unregisters a flight loop created by CreateFlightLoop and
+// releases its callback. Unknown or already destroyed IDs are ignored.
 func DestroyFlightLoop(loopID FlightLoopID) {
-	// A more robust implementation would need to look up the refcon/id associated with the loopID.
-	// For now, we assume the user manages the ID returned from CreateFlightLoop.
-	// This requires a reverse mapping from FlightLoopID to our internal uintptr id.
-	// For simplicity in this example, we'll just destroy the C-side object.
-	// A real library would need: var idToLoopIDMap = make(map[uintptr]FlightLoopID)
-	C.XPLMDestroyFlightLoop(C.XPLMFlightLoopID(loopID))
-	// unregisterCallback(id) // Would be called here.
+	registryMutex.RLock()
+	loop := byHandle[loopID]
+	registryMutex.RUnlock()
+	if loop != nil {
+		loop.Destroy()
+	}
 }
 
 // ScheduleFlightLoop schedules (or re-schedules) a flight loop.
@@ -0,0 +1,194 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/util"
+)
+
+// checkNoLoops fails the test if flight loops are left over, in the library
+// or in the simulator.
+func checkNoLoops(t *testing.T) {
+	t.Helper()
+	if loops := LiveFlightLoops(); len(loops) != 0 {
+		t.Errorf("%d live flight loops, first %s", len(loops), loops[0])
+	}
+	if n := xplmfake.FlightLoops(); n != 0 {
+		t.Errorf("%d flight loops not destroyed in the simulator", n)
+	}
+}
+
+func TestLiveFlightLoops(t *testing.T) {
+	checkNoLoops(t)
+
+	calls := 0
+	counting := NewFlightLoop(BeforeFlightModel, func(_, _ float32, _ int) float32 {
+		calls++
+		return -1
+	})
+	idle := NewFlightLoop(AfterFlightModel, func(_, _ float32, _ int) float32 { return 0 })
+	id := CreateFlightLoop(BeforeFlightModel, func(_, _ float32, _ int) float32 { return 0 })
+
+	live := LiveFlightLoops()
+	if len(live) != 3 || live[0] != counting || live[1] != idle || live[2].ID() != id {
+		t.Fatalf("LiveFlightLoops = %v, want the three loops in creation order", live)
+	}
+	for _, loop := range live {
+		if !strings.Contains(loop.String(), "processing_test.go:") {
+			t.Errorf("String() = %q, want the creating line", loop.String())
+		}
+	}
+
+	counting.Schedule(-1, true)
+	xplmfake.Frame(0.05)
+	xplmfake.Frame(0.05)
+	if calls != 2 {
+		t.Errorf("flight loop called %d times in 2 frames", calls)
+	}
+
+	counting.Destroy()
+	counting.Destroy()
+	idle.Destroy()
+	DestroyFlightLoop(id)
+	if !counting.Destroyed() || counting.ID() != nil {
+		t.Error("flight loop not destroyed")
+	}
+	// Scheduling a destroyed loop does nothing.
+	counting.Schedule(-1, true)
+	xplmfake.Frame(0.05)
+	if calls != 2 {
+		t.Errorf("destroyed flight loop called")
+	}
+	checkNoLoops(t)
+}
+
+func TestDispatcher(t *testing.T) {
+	defer checkNoLoops(t)
+	if err := RunOnMainThread(func() {}); !errors.Is(err, ErrDispatcherStopped) {
+		t.Errorf("RunOnMainThread before StartDispatcher: err = %v", err)
+	}
+	if f := CallOnMainThread(func() (int, error) { return 1, nil }); !f.Ready() {
+		t.Error("future not failed right away without a dispatcher")
+	} else if _, err := f.Wait(context.Background()); !errors.Is(err, ErrDispatcherStopped) {
+		t.Errorf("CallOnMainThread before StartDispatcher: err = %v", err)
+	}
+
+	StartDispatcher(3)
+	defer StopDispatcher()
+	var ran []int
+	for i := range 2 {
+		if err := RunOnMainThread(func() { ran = append(ran, i) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	answer := CallOnMainThread(func() (int, error) { return 42, nil })
+	if err := RunOnMainThread(func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("RunOnMainThread on a full queue: err = %v, want ErrQueueFull", err)
+	}
+	if ran != nil || answer.Ready() {
+		t.Fatal("queued functions ran before the next frame")
+	}
+	xplmfake.Frame(0.05)
+	if !slices.Equal(ran, []int{0, 1}) {
+		t.Errorf("ran %v, want [0 1]", ran)
+	}
+	if v, err := answer.Wait(context.Background()); v != 42 || err != nil {
+		t.Errorf("future = %v, %v, want 42", v, err)
+	}
+
+	failing := CallOnMainThread(func() (string, error) { return "partial", util.ErrCommandNotFound })
+	panicking := CallOnMainThread(func() (string, error) { panic("boom") })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := failing.Wait(ctx); err != context.Canceled {
+		t.Errorf("Wait with a cancelled context: err = %v", err)
+	}
+	xplmfake.Frame(0.05)
+	if v, err := failing.Wait(context.Background()); v != "partial" || !errors.Is(err, util.ErrCommandNotFound) {
+		t.Errorf("failing future = %q, %v", v, err)
+	}
+	if _, err := panicking.Wait(context.Background()); !errors.Is(err, util.ErrPanicked) {
+		t.Errorf("panicking future: err = %v, want ErrPanicked", err)
+	}
+}
+
+func TestFrameBudget(t *testing.T) {
+	defer checkNoLoops(t)
+	StartDispatcher(0)
+	defer StopDispatcher()
+	defer SetFrameBudget(DefaultFrameBudget)
+
+	ran := 0
+	queue := func(n int, sleep time.Duration) {
+		for range n {
+			if err := RunOnMainThread(func() { ran++; time.Sleep(sleep) }); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// At least one function runs per frame.
+	SetFrameBudget(0)
+	queue(3, 0)
+	for frame := 1; frame <= 3; frame++ {
+		xplmfake.Frame(0.05)
+		if ran != frame {
+			t.Errorf("frame %d: ran %d functions with no budget, want one per frame", frame, ran)
+		}
+	}
+
+	ran = 0
+	SetFrameBudget(25 * time.Millisecond)
+	queue(4, 10*time.Millisecond)
+	xplmfake.Frame(0.05)
+	if ran != 3 {
+		t.Errorf("ran %d functions of 10ms in a 25ms budget, want 3", ran)
+	}
+	xplmfake.Frame(0.05)
+	if ran != 4 {
+		t.Errorf("ran %d functions after two frames, want 4", ran)
+	}
+}
+
+func TestStopDispatcher(t *testing.T) {
+	defer checkNoLoops(t)
+	StartDispatcher(0)
+	futures := []*Future[int]{
+		CallOnMainThread(func() (int, error) { return 1, nil }),
+		CallOnMainThread(func() (int, error) { return 2, nil }),
+	}
+	ran := false
+	RunOnMainThread(func() { ran = true })
+
+	StopDispatcher()
+	for i, f := range futures {
+		if !f.Ready() {
+			t.Fatalf("future %d not done after StopDispatcher", i)
+		}
+		if _, err := f.Wait(context.Background()); !errors.Is(err, ErrDispatcherStopped) {
+			t.Errorf("future %d: err = %v, want ErrDispatcherStopped", i, err)
+		}
+	}
+	xplmfake.Frame(0.05)
+	if ran {
+		t.Error("queued function ran after StopDispatcher")
+	}
+	if err := RunOnMainThread(func() {}); !errors.Is(err, ErrDispatcherStopped) {
+		t.Errorf("RunOnMainThread after StopDispatcher: err = %v", err)
+	}
+	StopDispatcher()
+
+	StartDispatcher(0)
+	f := CallOnMainThread(func() (int, error) { return 3, nil })
+	plugin.XPluginDisable()
+	if _, err := f.Wait(context.Background()); !errors.Is(err, ErrDispatcherStopped) {
+		t.Errorf("future queued when the plugin was disabled: err = %v", err)
+	}
+}
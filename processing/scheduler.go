@@ -0,0 +1,298 @@
+package processing
+
+// #cgo CFLAGS: -DXPLM200=1 -DXPLM210=1 -DXPLM410=1
+// #include <stdlib.h>
+// #include "XPLMDataAccess.h"
+// #include "XPLMProcessing.h"
+import "C"
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/util"
+)
+
+// Clock selects the time base of a timer.
+type Clock int
+
+const (
+	// WallClock is XPLMGetElapsedTime, which keeps running while the
+	// simulator is paused.
+	WallClock Clock = iota
+	// SimClock stops while the simulator is paused. It only excludes the
+	// pauses observed while a scheduler is running, and runs like the
+	// WallClock if sim/time/paused cannot be found.
+	SimClock
+)
+
+// GetElapsedTime returns the seconds elapsed since the simulator started,
+// paused or not.
+func GetElapsedTime() float64 {
+	return float64(C.XPLMGetElapsedTime())
+}
+
+// The sim clock is shared by all schedulers; it advances once per frame
+// whichever scheduler samples it first.
+var (
+	simNow      float64
+	lastElapsed float64
+	pausedRef   C.XPLMDataRef
+	clocksReady bool
+	simMutex    sync.Mutex
+)
+
+// sampleClocks advances the sim clock to the current frame and returns the
+// time on both clocks. It must be called on the simulator thread.
+func sampleClocks() (wall, sim float64) {
+	wall = GetElapsedTime()
+	simMutex.Lock()
+	defer simMutex.Unlock()
+	if !clocksReady {
+		name := C.CString("sim/time/paused")
+		pausedRef = C.XPLMFindDataRef(name)
+		C.free(unsafe.Pointer(name))
+		if pausedRef == nil {
+			util.DebugString("xplane-go: sim/time/paused not found, the sim clock will not stop during pauses\n")
+		}
+		simNow, lastElapsed = wall, wall
+		clocksReady = true
+	}
+	if wall > lastElapsed {
+		if pausedRef == nil || C.XPLMGetDatai(pausedRef) == 0 {
+			simNow += wall - lastElapsed
+		}
+		lastElapsed = wall
+	}
+	return wall, simNow
+}
+
+// SimTime returns the time on the SimClock: the seconds elapsed since the
+// simulator started, minus the pauses. It must be called on the simulator
+// thread.
+func SimTime() float64 {
+	_, sim := sampleClocks()
+	return sim
+}
+
+// Timer is a function scheduled by a Scheduler.
+type Timer struct {
+	scheduler *Scheduler
+	clock     Clock
+	due       float64
+	period    float64
+	periodic  bool
+	fn        func()
+	index     int // In the scheduler's heap, -1 when not scheduled.
+	cancelled bool
+	fired     bool // Only set for one-shot timers.
+}
+
+// Cancel stops the timer. It returns false if the timer already fired, or was
+// already cancelled. It is safe to call from any goroutine and from the
+// timer's own function.
+func (t *Timer) Cancel() bool {
+	s := t.scheduler
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if t.cancelled || t.fired {
+		return false
+	}
+	t.cancelled = true
+	if t.index >= 0 {
+		heap.Remove(&s.timers[t.clock], t.index)
+	}
+	return true
+}
+
+// run calls the timer's function. A panicking timer is cancelled, without
+// disabling the scheduler's other timers.
+func (t *Timer) run() {
+	defer util.RecoverPanic("timer", func() { t.Cancel() })
+	t.fn()
+}
+
+// timerHeap orders timers by due time.
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].due < h[j].due }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *timerHeap) Push(x any) {
+	t := x.(*Timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+func (h *timerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// Scheduler runs timers on both clocks from a single flight loop, created
+// with the first timer. Timer functions run on the simulator thread.
+type Scheduler struct {
+	phase  FlightLoopPhase
+	loop   *FlightLoop
+	timers [2]timerHeap // Indexed by Clock.
+	ready  []*Timer     // Only used by tick.
+	mutex  sync.Mutex
+}
+
+var defaultScheduler = NewScheduler(BeforeFlightModel)
+
+func init() {
+	plugin.OnDisable(defaultScheduler.Stop)
+}
+
+// NewScheduler creates a scheduler whose flight loop runs in phase.
+func NewScheduler(phase FlightLoopPhase) *Scheduler {
+	return &Scheduler{phase: phase}
+}
+
+// After runs fn once, delay from now on clock. It must be called on the
+// simulator thread.
+func (s *Scheduler) After(clock Clock, delay time.Duration, fn func()) *Timer {
+	return s.add(clock, delay.Seconds(), 0, false, fn)
+}
+
+// Every runs fn every period on clock, starting one period from now, or every
+// frame when period <= 0. A late timer does not try to catch up on the periods
+// it missed. It must be called on the simulator thread.
+func (s *Scheduler) Every(clock Clock, period time.Duration, fn func()) *Timer {
+	p := max(period.Seconds(), 0)
+	return s.add(clock, p, p, true, fn)
+}
+
+// AtSimTime runs fn once when SimTime reaches t, or on the next frame if it
+// already did. It must be called on the simulator thread.
+func (s *Scheduler) AtSimTime(t float64, fn func()) *Timer {
+	return s.add(SimClock, t-SimTime(), 0, false, fn)
+}
+
+func (s *Scheduler) add(clock Clock, delay, period float64, periodic bool, fn func()) *Timer {
+	wall, sim := sampleClocks()
+	now := wall
+	if clock == SimClock {
+		now = sim
+	}
+	t := &Timer{scheduler: s, clock: clock, due: now + delay, period: period, periodic: periodic, fn: fn}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	heap.Push(&s.timers[clock], t)
+	if s.loop == nil {
+		s.loop = NewFlightLoop(s.phase, s.tick)
+		s.loop.Schedule(-1, true)
+	}
+	return t
+}
+
+// Stop cancels all timers and destroys the flight loop. The scheduler can be
+// used again afterwards. The default scheduler is stopped when the plugin is
+// disabled.
+func (s *Scheduler) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for clock := range s.timers {
+		for _, t := range s.timers[clock] {
+			t.index = -1
+			t.cancelled = true
+		}
+		s.timers[clock] = nil
+	}
+	// When called from a timer, the rest of the frame's timers must not run.
+	for _, t := range s.ready {
+		if t != nil {
+			t.cancelled = true
+		}
+	}
+	if s.loop != nil {
+		s.loop.Destroy()
+		s.loop = nil
+	}
+}
+
+// tick runs the due timers. It keeps running every frame so that the sim
+// clock observes pauses.
+func (s *Scheduler) tick(_, _ float32, _ int) float32 {
+	wall, sim := sampleClocks()
+	s.ready = s.collect(s.ready[:0], wall, sim)
+	for i, t := range s.ready {
+		// An earlier timer may have cancelled this one.
+		if s.claim(t) {
+			t.run()
+		}
+		s.ready[i] = nil
+	}
+	return -1
+}
+
+// collect appends the due timers to ready, and schedules the periodic ones
+// again for a later frame.
+func (s *Scheduler) collect(ready []*Timer, wall, sim float64) []*Timer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	start := len(ready)
+	for clock, now := range [2]float64{wall, sim} {
+		h := &s.timers[clock]
+		for h.Len() > 0 && (*h)[0].due <= now {
+			ready = append(ready, heap.Pop(h).(*Timer))
+		}
+	}
+	for _, t := range ready[start:] {
+		if !t.periodic {
+			continue
+		}
+		now := wall
+		if t.clock == SimClock {
+			now = sim
+		}
+		t.due += t.period
+		if t.due <= now {
+			t.due = now + t.period
+		}
+		heap.Push(&s.timers[t.clock], t)
+	}
+	return ready
+}
+
+// claim reports whether t may run, marking one-shot timers as fired.
+func (s *Scheduler) claim(t *Timer) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if t.cancelled {
+		return false
+	}
+	if !t.periodic {
+		t.fired = true
+	}
+	return true
+}
+
+// After runs fn once, delay from now on clock, on the default scheduler. It
+// must be called on the simulator thread.
+func After(clock Clock, delay time.Duration, fn func()) *Timer {
+	return defaultScheduler.After(clock, delay, fn)
+}
+
+// Every runs fn every period on clock, on the default scheduler. It must be
+// called on the simulator thread.
+func Every(clock Clock, period time.Duration, fn func()) *Timer {
+	return defaultScheduler.Every(clock, period, fn)
+}
+
+// AtSimTime runs fn once when SimTime reaches t, on the default scheduler. It
+// must be called on the simulator thread.
+func AtSimTime(t float64, fn func()) *Timer {
+	return defaultScheduler.AtSimTime(t, fn)
+}
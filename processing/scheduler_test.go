@@ -0,0 +1,175 @@
+package processing
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+)
+
+// resetClocks makes the next sampleClocks look up sim/time/paused again.
+func resetClocks() {
+	simMutex.Lock()
+	clocksReady = false
+	simMutex.Unlock()
+}
+
+func TestAfter(t *testing.T) {
+	s := NewScheduler(BeforeFlightModel)
+	defer checkNoLoops(t)
+	defer s.Stop()
+
+	start := GetElapsedTime()
+	fired := make(map[string]float64)
+	after := func(name string, delay time.Duration) *Timer {
+		return s.After(WallClock, delay, func() { fired[name] = GetElapsedTime() - start })
+	}
+	after("short", 100*time.Millisecond)
+	after("long", 300*time.Millisecond)
+	cancelled := after("cancelled", 200*time.Millisecond)
+	fired200 := after("fired", 200*time.Millisecond)
+	if !cancelled.Cancel() || cancelled.Cancel() {
+		t.Error("Cancel should succeed once")
+	}
+	for range 50 {
+		xplmfake.Frame(0.01)
+	}
+	for name, delay := range map[string]float64{"short": 0.1, "long": 0.3, "fired": 0.2} {
+		if at, ok := fired[name]; !ok || at < delay-1e-4 || at > delay+0.0101 {
+			t.Errorf("%s timer fired at %v (%v), want within a frame after %v", name, at, ok, delay)
+		}
+	}
+	if _, ok := fired["cancelled"]; ok {
+		t.Error("cancelled timer fired")
+	}
+	if fired200.Cancel() {
+		t.Error("Cancel succeeded on a fired timer")
+	}
+}
+
+func TestTimerOrder(t *testing.T) {
+	s := NewScheduler(AfterFlightModel)
+	defer checkNoLoops(t)
+	defer s.Stop()
+
+	// Timers due in the same frame run in due order, whatever the order they
+	// were added in.
+	delays := []int{70, 20, 90, 10, 50, 30, 80, 60, 40, 100}
+	var got []int
+	for _, d := range delays {
+		s.After(WallClock, time.Duration(d)*time.Millisecond, func() { got = append(got, d) })
+	}
+	s.After(WallClock, 400*time.Millisecond, func() { got = append(got, 400) })
+	// Cancelling from the heap's middle keeps it ordered.
+	s.After(WallClock, 45*time.Millisecond, func() { got = append(got, 45) }).Cancel()
+	xplmfake.Frame(0.2)
+	want := slices.Sorted(slices.Values(delays))
+	if !slices.Equal(got, want) {
+		t.Errorf("timers ran in order %v, want %v", got, want)
+	}
+	xplmfake.Frame(0.3)
+	if len(got) != len(want)+1 || got[len(want)] != 400 {
+		t.Errorf("later timer: got %v", got)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	s := NewScheduler(BeforeFlightModel)
+	defer checkNoLoops(t)
+	defer s.Stop()
+
+	periodic, perFrame, cancelled := 0, 0, 0
+	s.Every(WallClock, 100*time.Millisecond, func() { periodic++ })
+	s.Every(WallClock, 0, func() { perFrame++ })
+	var self *Timer
+	self = s.Every(WallClock, 50*time.Millisecond, func() {
+		if cancelled++; cancelled == 3 {
+			self.Cancel()
+		}
+	})
+	for range 100 {
+		xplmfake.Frame(0.01)
+	}
+	if periodic < 9 || periodic > 10 {
+		t.Errorf("100ms timer ran %d times in 1s", periodic)
+	}
+	if perFrame != 100 {
+		t.Errorf("per-frame timer ran %d times in 100 frames", perFrame)
+	}
+	if cancelled != 3 {
+		t.Errorf("timer cancelling itself ran %d times, want 3", cancelled)
+	}
+
+	// A late timer does not catch up.
+	periodic = 0
+	xplmfake.Frame(1)
+	xplmfake.Frame(0.05)
+	if periodic != 1 {
+		t.Errorf("100ms timer ran %d times after a 1s frame, want 1", periodic)
+	}
+	xplmfake.Frame(0.06)
+	if periodic != 2 {
+		t.Errorf("100ms timer ran %d times a period after a late run, want 2", periodic)
+	}
+}
+
+func TestSimTimeWithoutPausedDataRef(t *testing.T) {
+	resetClocks()
+	xplmfake.ClearLog()
+	s := NewScheduler(BeforeFlightModel)
+	defer checkNoLoops(t)
+	defer s.Stop()
+
+	fired := 0
+	s.AtSimTime(SimTime()+0.1, func() { fired++ })
+	for range 4 {
+		xplmfake.Frame(0.05)
+	}
+	if fired != 1 {
+		t.Errorf("AtSimTime timer fired %d times, want 1", fired)
+	}
+	if n := strings.Count(xplmfake.Log(), "sim/time/paused not found"); n != 1 {
+		t.Errorf("missing dataref logged %d times, want once:\n%s", n, xplmfake.Log())
+	}
+}
+
+func TestSimClockPause(t *testing.T) {
+	xplmfake.AddDataRef("sim/time/paused", xplmfake.TypeInt, false)
+	resetClocks()
+	defer resetClocks()
+	defer xplmfake.RemoveDataRef("sim/time/paused")
+	s := NewScheduler(BeforeFlightModel)
+	defer checkNoLoops(t)
+	defer s.Stop()
+
+	simFired, wallFired := false, false
+	start := SimTime()
+	s.After(SimClock, 500*time.Millisecond, func() { simFired = true })
+	s.After(WallClock, 500*time.Millisecond, func() { wallFired = true })
+
+	xplmfake.Frame(0.25)
+	xplmfake.SetValue("sim/time/paused", 1)
+	for range 4 {
+		xplmfake.Frame(0.25)
+	}
+	if !wallFired || simFired {
+		t.Errorf("during a pause: wall timer fired %v, sim timer fired %v, want only the wall timer", wallFired, simFired)
+	}
+	if d := SimTime() - start; math.Abs(d-0.25) > 1e-4 {
+		t.Errorf("sim clock advanced %vs in 0.25s before the pause, want 0.25", d)
+	}
+
+	xplmfake.SetValue("sim/time/paused", 0)
+	xplmfake.Frame(0.125)
+	if simFired {
+		t.Error("sim timer fired 0.375s into the sim clock")
+	}
+	xplmfake.Frame(0.125)
+	xplmfake.Frame(0.125)
+	if !simFired {
+		t.Error("sim timer did not fire after the pause")
+	}
+}
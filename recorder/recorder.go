@@ -0,0 +1,316 @@
+// Package recorder samples datarefs at a fixed rate into a flightlog file,
+// for replay or debrief after the flight.
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/flightlog"
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/processing"
+	"github.com/akhenakh/xplane-go/util"
+)
+
+var (
+	ErrRecording    = errors.New("recorder: already recording")
+	ErrNotRecording = errors.New("recorder: not recording")
+)
+
+// DefaultRate is the sample rate used when Config.Rate is not set.
+const DefaultRate = 10
+
+// Config describes what a Recorder samples.
+type Config struct {
+	DataRefs []string                   // Scalar, array or byte datarefs to record.
+	Rate     float64                    // Samples per second, DefaultRate if 0.
+	Dir      string                     // Output directory, LogDir() if empty.
+	Phase    processing.FlightLoopPhase // Flight loop phase samples are taken in.
+}
+
+// LogDir returns the default directory for flight logs, a "flightlogs"
+// folder next to the X-Plane preferences.
+func LogDir() string {
+	return filepath.Join(filepath.Dir(util.GetPrefsPath()), "flightlogs")
+}
+
+type channel struct {
+	name   string
+	types  dref.DataType
+	ints   []int32
+	floats []float32
+	bytes  []byte
+}
+
+// Recorder writes one flightlog file per recording session. Samples are taken
+// in a flight loop on the simulator thread.
+type Recorder struct {
+	cache    *dref.DataRefCache
+	config   Config
+	channels []channel
+	header   flightlog.Header
+
+	mutex   sync.Mutex
+	loop    *processing.FlightLoop
+	file    *os.File
+	writer  *flightlog.Writer
+	path    string
+	elapsed float64
+	samples int
+	values  []float64
+	err     error
+}
+
+var (
+	active      = make(map[*Recorder]bool)
+	activeMutex sync.Mutex
+)
+
+func init() {
+	// Flush and close any log still open when the plugin goes away.
+	plugin.OnDisable(func() {
+		activeMutex.Lock()
+		recorders := make([]*Recorder, 0, len(active))
+		for r := range active {
+			recorders = append(recorders, r)
+		}
+		activeMutex.Unlock()
+		for _, r := range recorders {
+			r.Stop()
+		}
+	})
+}
+
+// New registers the configured datarefs in cache and prepares a recorder.
+// The type and size of every dataref is fixed at this point.
+func New(cache *dref.DataRefCache, config Config) (*Recorder, error) {
+	if config.Rate <= 0 {
+		config.Rate = DefaultRate
+	}
+	if config.Dir == "" {
+		config.Dir = LogDir()
+	}
+	r := &Recorder{cache: cache, config: config}
+	r.header.Rate = config.Rate
+
+	for _, name := range config.DataRefs {
+		if err := cache.Register(name); err != nil {
+			return nil, fmt.Errorf("recorder: %w", err)
+		}
+		types, err := cache.Type(name)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: %w", err)
+		}
+		ch := channel{name: name, types: types}
+		desc := flightlog.Channel{Name: name, Count: 1}
+		size, _ := cache.ArrayLen(name)
+		switch {
+		case types&dref.TypeDouble != 0:
+			desc.Type = flightlog.Float64
+		case types&dref.TypeFloat != 0:
+			desc.Type = flightlog.Float32
+		case types&dref.TypeInt != 0:
+			desc.Type = flightlog.Int32
+		case types&dref.TypeFloatArray != 0:
+			desc.Type, desc.Count = flightlog.Float32, size
+			ch.floats = make([]float32, size)
+		case types&dref.TypeIntArray != 0:
+			desc.Type, desc.Count = flightlog.Int32, size
+			ch.ints = make([]int32, size)
+		case types&dref.TypeData != 0:
+			desc.Type, desc.Count = flightlog.Byte, size
+			ch.bytes = make([]byte, size)
+		default:
+			return nil, fmt.Errorf("recorder: dataref '%s' has unsupported type %s", name, types)
+		}
+		if desc.Count == 0 {
+			return nil, fmt.Errorf("recorder: dataref '%s' is an empty array", name)
+		}
+		r.channels = append(r.channels, ch)
+		r.header.Channels = append(r.header.Channels, desc)
+	}
+	r.values = make([]float64, 0, r.header.Width())
+	return r, nil
+}
+
+// Header returns the layout of the logs written by the recorder.
+func (r *Recorder) Header() flightlog.Header {
+	return r.header
+}
+
+// Start creates a new log file in the configured directory and starts
+// sampling. It returns the path of the file.
+func (r *Recorder) Start() (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file != nil {
+		return "", ErrRecording
+	}
+
+	if err := os.MkdirAll(r.config.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("recorder: %w", err)
+	}
+	start := time.Now()
+	file, path, err := createLog(r.config.Dir, start)
+	if err != nil {
+		return "", fmt.Errorf("recorder: %w", err)
+	}
+	header := r.header
+	header.Start = start
+	writer, err := flightlog.NewWriter(file, header)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("recorder: %w", err)
+	}
+
+	r.file, r.writer, r.path = file, writer, path
+	r.elapsed, r.samples, r.err = 0, 0, nil
+	r.loop = processing.NewFlightLoop(r.config.Phase, r.sample)
+	r.loop.Schedule(-1, true)
+
+	activeMutex.Lock()
+	active[r] = true
+	activeMutex.Unlock()
+	return path, nil
+}
+
+// createLog creates a log file named after start. Sessions started within the
+// same second get a counter appended to the name.
+func createLog(dir string, start time.Time) (*os.File, string, error) {
+	name := start.Format("flight-20060102-150405")
+	for n := 1; ; n++ {
+		path := filepath.Join(dir, name+".xpfl")
+		if n > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d.xpfl", name, n))
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil || !errors.Is(err, fs.ErrExist) {
+			return file, path, err
+		}
+	}
+}
+
+// Stop stops sampling and closes the log file.
+func (r *Recorder) Stop() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil {
+		return ErrNotRecording
+	}
+
+	activeMutex.Lock()
+	delete(active, r)
+	activeMutex.Unlock()
+
+	r.loop.Destroy()
+	r.loop = nil
+	err := r.writer.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file, r.writer = nil, nil
+	if err == nil {
+		err = r.err
+	}
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+	return nil
+}
+
+// Recording reports whether a session is in progress.
+func (r *Recorder) Recording() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file != nil
+}
+
+// Path returns the file of the current or last session.
+func (r *Recorder) Path() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.path
+}
+
+// Err returns the write error that interrupted the current or last session,
+// if any. Sampling stops at the first error, but the file stays open until
+// Stop is called.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+func (r *Recorder) sample(elapsedSinceLastCall, _ float32, _ int) float32 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.writer == nil || r.err != nil {
+		return 0
+	}
+
+	// The first sample is taken at time 0, whatever preceded it.
+	if r.samples > 0 {
+		r.elapsed += float64(elapsedSinceLastCall)
+	}
+	r.values = r.values[:0]
+	for i := range r.channels {
+		r.values = r.channels[i].read(r.cache, r.values)
+	}
+	if err := r.writer.Write(r.elapsed, r.values); err != nil {
+		r.err = err
+		return 0
+	}
+	r.samples++
+	// Flush about once a second, so a crash loses at most that much.
+	if r.samples%max(int(r.config.Rate), 1) == 0 {
+		if err := r.writer.Flush(); err != nil {
+			r.err = err
+			return 0
+		}
+	}
+	return float32(1 / r.config.Rate)
+}
+
+// read appends the current values of the channel to values.
+func (c *channel) read(cache *dref.DataRefCache, values []float64) []float64 {
+	switch {
+	case c.floats != nil:
+		clear(c.floats)
+		cache.GetFloatArray(c.name, c.floats, 0)
+		for _, v := range c.floats {
+			values = append(values, float64(v))
+		}
+	case c.ints != nil:
+		clear(c.ints)
+		cache.GetIntArray(c.name, c.ints, 0)
+		for _, v := range c.ints {
+			values = append(values, float64(v))
+		}
+	case c.bytes != nil:
+		clear(c.bytes)
+		cache.GetBytes(c.name, c.bytes)
+		for _, v := range c.bytes {
+			values = append(values, float64(v))
+		}
+	case c.types&dref.TypeDouble != 0:
+		v, _ := cache.GetDouble(c.name)
+		values = append(values, v)
+	case c.types&dref.TypeFloat != 0:
+		v, _ := cache.GetFloat(c.name)
+		values = append(values, float64(v))
+	default:
+		v, _ := cache.GetInt(c.name)
+		values = append(values, float64(v))
+	}
+	return values
+}
@@ -0,0 +1,25 @@
+package recorder
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/akhenakh/xplane-go/internal/xplmfake"
+)
+
+func TestCreateLogSameSecond(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2025, 6, 1, 14, 30, 0, 0, time.Local)
+	want := []string{"flight-20250601-143000.xpfl", "flight-20250601-143000-2.xpfl", "flight-20250601-143000-3.xpfl"}
+	for _, name := range want {
+		file, path, err := createLog(dir, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		if path != filepath.Join(dir, name) {
+			t.Errorf("path = %s, want %s", path, name)
+		}
+	}
+}
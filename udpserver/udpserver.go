@@ -0,0 +1,238 @@
+// Package udpserver runs an xpudp.Server inside a plugin, so that external
+// tools speaking X-Plane's UDP protocol can subscribe to datarefs, write them
+// and run commands through the plugin.
+package udpserver
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/processing"
+	"github.com/akhenakh/xplane-go/util"
+	"github.com/akhenakh/xplane-go/xpudp"
+)
+
+const (
+	// MaxDataRefs is the number of datarefs a server registers in its cache
+	// on behalf of clients. Further names are refused with ErrTooManyDataRefs.
+	MaxDataRefs = 4096
+	// maxMissing bounds the unknown names remembered between searches.
+	maxMissing = 1024
+)
+
+var (
+	ErrTooManyDataRefs = errors.New("too many datarefs requested over UDP")
+)
+
+// Server is a UDP protocol server whose packets are applied, and whose
+// subscriptions are answered, every frame on the simulator thread.
+type Server struct {
+	*xpudp.Server
+	sim    *cacheSim
+	loop   *processing.FlightLoop
+	errors chan error
+	mutex  sync.Mutex
+}
+
+var (
+	active      = make(map[*Server]bool)
+	activeMutex sync.Mutex
+)
+
+func init() {
+	plugin.OnDisable(func() {
+		activeMutex.Lock()
+		servers := make([]*Server, 0, len(active))
+		for s := range active {
+			servers = append(servers, s)
+		}
+		activeMutex.Unlock()
+		for _, s := range servers {
+			s.Stop()
+		}
+	})
+}
+
+// Start listens on a UDP address such as ":49010" and serves datarefs from
+// cache, registering them on first use. It is stopped automatically when the
+// plugin is disabled. Do not use X-Plane's own port 49000 when the simulator
+// is listening on it.
+func Start(address string, cache *dref.DataRefCache) (*Server, error) {
+	sim := newCacheSim(cache)
+	udp, err := xpudp.Listen(address, sim)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Server: udp, sim: sim, errors: make(chan error, 64)}
+	// OnError is also called from the receiving goroutine, where the SDK must
+	// not be used, so errors are logged from the flight loop.
+	udp.OnError = func(err error) {
+		select {
+		case s.errors <- err:
+		default:
+		}
+	}
+	go udp.Serve()
+
+	s.loop = processing.NewFlightLoop(processing.AfterFlightModel, s.tick)
+	s.loop.Schedule(-1, true)
+
+	activeMutex.Lock()
+	active[s] = true
+	activeMutex.Unlock()
+	return s, nil
+}
+
+// Stop closes the socket and destroys the flight loop.
+func (s *Server) Stop() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.loop == nil {
+		return nil
+	}
+	activeMutex.Lock()
+	delete(active, s)
+	activeMutex.Unlock()
+
+	s.loop.Destroy()
+	s.loop = nil
+	return s.Server.Close()
+}
+
+// ReceiveMessage implements plugin.MessageHandler. Forward the plugin's
+// messages to it so that datarefs published after a client asked for them
+// are served.
+func (s *Server) ReceiveMessage(from plugin.PluginID, msg plugin.Message, param unsafe.Pointer) {
+	switch msg {
+	case plugin.MsgDataRefsAdded, plugin.MsgPlaneLoaded:
+		// Like Tick, messages are received on the simulator thread.
+		clear(s.sim.missing)
+	}
+}
+
+func (s *Server) tick(elapsedSinceLastCall, _ float32, _ int) float32 {
+	s.Server.Tick(float64(elapsedSinceLastCall))
+	for {
+		select {
+		case err := <-s.errors:
+			util.DebugString(fmt.Sprintf("udpserver: %v\n", err))
+		default:
+			return -1
+		}
+	}
+}
+
+// cacheSim implements xpudp.Sim on top of a DataRefCache.
+type cacheSim struct {
+	cache      *dref.DataRefCache
+	commands   map[string]util.CommandRef
+	missing    map[string]bool
+	registered int // Datarefs added to the cache by the server.
+	floats     [1]float32
+	ints       [1]int32
+}
+
+func newCacheSim(cache *dref.DataRefCache) *cacheSim {
+	return &cacheSim{
+		cache:    cache,
+		commands: make(map[string]util.CommandRef),
+		missing:  make(map[string]bool),
+	}
+}
+
+// lookup registers a dataref and returns its types. Unknown names are
+// remembered until datarefs are added, so subscriptions to them do not search
+// for the dataref every frame.
+func (s *cacheSim) lookup(name string) (dref.DataType, error) {
+	if types, err := s.cache.Type(name); err == nil {
+		return types, nil
+	}
+	if s.missing[name] {
+		return 0, fmt.Errorf("dataref '%s': %w", name, dref.ErrDataRefNotFound)
+	}
+	if s.registered >= MaxDataRefs {
+		return 0, fmt.Errorf("dataref '%s': %w", name, ErrTooManyDataRefs)
+	}
+	if err := s.cache.Register(name); err != nil {
+		if len(s.missing) >= maxMissing {
+			clear(s.missing)
+		}
+		s.missing[name] = true
+		return 0, err
+	}
+	s.registered++
+	return s.cache.Type(name)
+}
+
+func (s *cacheSim) Read(name string, index int) (float32, error) {
+	types, err := s.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	if index >= 0 {
+		switch {
+		case types&dref.TypeFloatArray != 0:
+			_, err = s.cache.GetFloatArray(name, s.floats[:], index)
+			return s.floats[0], err
+		case types&dref.TypeIntArray != 0:
+			_, err = s.cache.GetIntArray(name, s.ints[:], index)
+			return float32(s.ints[0]), err
+		}
+		return 0, fmt.Errorf("dataref '%s' is %s, not an array: %w", name, types, dref.ErrTypeMismatch)
+	}
+	switch {
+	case types&dref.TypeFloat != 0:
+		return s.cache.GetFloat(name)
+	case types&dref.TypeDouble != 0:
+		v, err := s.cache.GetDouble(name)
+		return float32(v), err
+	case types&dref.TypeInt != 0:
+		v, err := s.cache.GetInt(name)
+		return float32(v), err
+	}
+	return 0, fmt.Errorf("dataref '%s' is %s, not a scalar: %w", name, types, dref.ErrTypeMismatch)
+}
+
+func (s *cacheSim) Write(name string, index int, value float32) error {
+	types, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+	if index >= 0 {
+		switch {
+		case types&dref.TypeFloatArray != 0:
+			s.floats[0] = value
+			return s.cache.SetFloatArray(name, s.floats[:], index)
+		case types&dref.TypeIntArray != 0:
+			s.ints[0] = int32(value)
+			return s.cache.SetIntArray(name, s.ints[:], index)
+		}
+		return fmt.Errorf("dataref '%s' is %s, not an array: %w", name, types, dref.ErrTypeMismatch)
+	}
+	switch {
+	case types&dref.TypeFloat != 0:
+		return s.cache.SetFloat(name, value)
+	case types&dref.TypeDouble != 0:
+		return s.cache.SetDouble(name, float64(value))
+	case types&dref.TypeInt != 0:
+		return s.cache.SetInt(name, int(value))
+	}
+	return fmt.Errorf("dataref '%s' is %s, not a scalar: %w", name, types, dref.ErrTypeMismatch)
+}
+
+func (s *cacheSim) Command(name string) error {
+	ref, ok := s.commands[name]
+	if !ok {
+		var err error
+		if ref, err = util.FindCommand(name); err != nil {
+			return err
+		}
+		s.commands[name] = ref
+	}
+	util.CommandOnce(ref)
+	return nil
+}
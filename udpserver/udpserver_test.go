@@ -0,0 +1,50 @@
+package udpserver
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+	"github.com/akhenakh/xplane-go/plugin"
+)
+
+func TestLateDataRef(t *testing.T) {
+	sim := newCacheSim(dref.NewDataRefCache())
+	s := &Server{sim: sim}
+	const name = "xplane-go/test/udpserver_late"
+
+	if _, err := sim.Read(name, -1); !errors.Is(err, dref.ErrDataRefNotFound) {
+		t.Fatalf("Read before publishing: err = %v, want ErrDataRefNotFound", err)
+	}
+	xplmfake.AddDataRef(name, xplmfake.TypeFloat, true)
+	if _, err := sim.Read(name, -1); !errors.Is(err, dref.ErrDataRefNotFound) {
+		t.Fatalf("Read before the message: err = %v, want the name still missing", err)
+	}
+
+	s.ReceiveMessage(plugin.PluginID(0), plugin.MsgDataRefsAdded, nil)
+	if err := sim.Write(name, -1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := sim.Read(name, -1); err != nil || v != 3 {
+		t.Errorf("Read = %v, %v, want 3", v, err)
+	}
+}
+
+func TestLookupLimits(t *testing.T) {
+	sim := newCacheSim(dref.NewDataRefCache())
+	for i := range maxMissing + 10 {
+		sim.lookup(fmt.Sprintf("xplane-go/test/udpserver_unknown_%d", i))
+	}
+	if len(sim.missing) > maxMissing {
+		t.Errorf("%d missing names remembered, want at most %d", len(sim.missing), maxMissing)
+	}
+
+	const name = "xplane-go/test/udpserver_limit"
+	xplmfake.AddDataRef(name, xplmfake.TypeInt, true)
+	sim.registered = MaxDataRefs
+	if _, err := sim.lookup(name); !errors.Is(err, ErrTooManyDataRefs) {
+		t.Errorf("lookup past the limit: err = %v, want ErrTooManyDataRefs", err)
+	}
+}
@@ -0,0 +1,45 @@
+package util
+
+// #cgo CFLAGS: -DXPLM410=1
+// #include <stdlib.h>
+// #include "XPLMUtilities.h"
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+var (
+	ErrCommandNotFound = errors.New("command not found")
+)
+
+// CommandRef is an opaque handle to an X-Plane command.
+type CommandRef unsafe.Pointer
+
+// FindCommand looks up a command such as "sim/operation/pause_toggle".
+func FindCommand(name string) (CommandRef, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	ref := C.XPLMFindCommand(cName)
+	if ref == nil {
+		return nil, fmt.Errorf("command '%s': %w", name, ErrCommandNotFound)
+	}
+	return CommandRef(ref), nil
+}
+
+// CommandOnce starts and immediately ends a command, like a button press.
+func CommandOnce(ref CommandRef) {
+	C.XPLMCommandOnce(C.XPLMCommandRef(ref))
+}
+
+// CommandBegin starts a command that stays active until CommandEnd, like a
+// button held down.
+func CommandBegin(ref CommandRef) {
+	C.XPLMCommandBegin(C.XPLMCommandRef(ref))
+}
+
+// CommandEnd ends a command started with CommandBegin.
+func CommandEnd(ref CommandRef) {
+	C.XPLMCommandEnd(C.XPLMCommandRef(ref))
+}
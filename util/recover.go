@@ -0,0 +1,56 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrPanicked is returned in place of the result of a function that panicked.
+var ErrPanicked = errors.New("callback panicked")
+
+// PanicInfo describes a panic recovered from a callback called by X-Plane.
+type PanicInfo struct {
+	Callback string // Kind of callback, e.g. "flight loop".
+	Value    any    // Value passed to panic.
+	Stack    []byte // Stack of the panicking goroutine.
+}
+
+var (
+	panicHandler      func(PanicInfo)
+	panicHandlerMutex sync.RWMutex
+)
+
+// SetPanicHandler sets a function called with every recovered panic, after
+// it was logged, e.g. to report crashes. nil removes it.
+func SetPanicHandler(handler func(PanicInfo)) {
+	panicHandlerMutex.Lock()
+	defer panicHandlerMutex.Unlock()
+	panicHandler = handler
+}
+
+// RecoverPanic stops a panic from unwinding into X-Plane, which would crash
+// the simulator. Callbacks called by X-Plane defer it directly:
+//
+//	defer util.RecoverPanic("flight loop", func() { next = 0 })
+//
+// On panic it logs the value and stack to Log.txt, calls onPanic, if not nil,
+// to disable the offending callback, then calls the panic handler.
+func RecoverPanic(callback string, onPanic func()) {
+	if r := recover(); r != nil {
+		info := PanicInfo{Callback: callback, Value: r, Stack: debug.Stack()}
+		DebugString(fmt.Sprintf("xplane-go: panic in %s: %v\n%s\n", callback, r, info.Stack))
+		if onPanic != nil {
+			onPanic()
+		}
+		panicHandlerMutex.RLock()
+		handler := panicHandler
+		panicHandlerMutex.RUnlock()
+		if handler != nil {
+			// A panicking handler must not escape either.
+			defer func() { recover() }()
+			handler(info)
+		}
+	}
+}
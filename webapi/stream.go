@@ -0,0 +1,205 @@
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	// DefaultRate is the update rate of WebSocket subscriptions in Hz.
+	DefaultRate = 10
+	// sendQueueSize is the number of messages buffered per WebSocket
+	// connection. Updates are dropped when the client does not keep up.
+	sendQueueSize = 64
+)
+
+// request is a message from a WebSocket client.
+type request struct {
+	Type     string          `json:"type"`
+	ID       json.RawMessage `json:"id,omitempty"`
+	DataRefs []string        `json:"datarefs,omitempty"`
+	Rate     float64         `json:"rate,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Duration float64         `json:"duration,omitempty"`
+}
+
+// response is a message to a WebSocket client.
+type response struct {
+	Type   string          `json:"type"`
+	ID     json.RawMessage `json:"id,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Values map[string]any  `json:"values,omitempty"`
+}
+
+type streamSubscription struct {
+	period float64
+	next   float64
+}
+
+// session is a WebSocket connection. Its subscriptions are only used on the
+// simulator thread.
+type session struct {
+	server    *Server
+	ws        *wsConn
+	send      chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	subs map[string]*streamSubscription
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	sess := &session{
+		server: s,
+		ws:     ws,
+		send:   make(chan []byte, sendQueueSize),
+		closed: make(chan struct{}),
+		subs:   make(map[string]*streamSubscription),
+	}
+	ctx := context.Background()
+	if err := s.do(ctx, func() error { s.sessions[sess] = true; return nil }); err != nil {
+		ws.Close(closeGoingAway)
+		return
+	}
+	defer func() {
+		s.do(ctx, func() error { delete(s.sessions, sess); return nil })
+		sess.close(closeNormal)
+	}()
+	go sess.write()
+
+	for {
+		message, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req request
+		if err := json.Unmarshal(message, &req); err != nil {
+			sess.reply(response{Type: "error", Error: "malformed request: " + err.Error()})
+			continue
+		}
+		if err := sess.handle(ctx, req); err != nil {
+			if errors.Is(err, ErrStopped) {
+				return
+			}
+			sess.reply(response{Type: "error", ID: req.ID, Error: err.Error()})
+			continue
+		}
+		sess.reply(response{Type: "result", ID: req.ID})
+	}
+}
+
+func (sess *session) handle(ctx context.Context, req request) error {
+	s := sess.server
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	switch req.Type {
+	case "subscribe":
+		rate := req.Rate
+		if rate <= 0 {
+			rate = DefaultRate
+		}
+		return s.do(ctx, func() error {
+			var errs []error
+			for _, name := range req.DataRefs {
+				// Only subscribe to datarefs that can be read.
+				if _, err := s.readValue(name); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				sess.subs[name] = &streamSubscription{period: 1 / rate, next: s.clock}
+			}
+			return errors.Join(errs...)
+		})
+	case "unsubscribe":
+		return s.do(ctx, func() error {
+			for _, name := range req.DataRefs {
+				delete(sess.subs, name)
+			}
+			return nil
+		})
+	case "set":
+		return s.do(ctx, func() error { return s.writeValue(req.Name, req.Value) })
+	case "command":
+		return s.do(ctx, func() error { return s.command(req.Name, req.Duration) })
+	}
+	return fmt.Errorf("unknown request type %q", req.Type)
+}
+
+// update sends the values of the due subscriptions. It is called on the
+// simulator thread.
+func (sess *session) update(clock float64) {
+	var values map[string]any
+	for name, sub := range sess.subs {
+		if sub.next > clock {
+			continue
+		}
+		sub.next += sub.period
+		if sub.next <= clock {
+			// Do not try to catch up after a long frame.
+			sub.next = clock + sub.period
+		}
+		v, err := sess.server.readValue(name)
+		if err != nil {
+			// E.g. a plugin dataref that was unpublished.
+			continue
+		}
+		if values == nil {
+			values = make(map[string]any)
+		}
+		values[name] = v
+	}
+	if values == nil {
+		return
+	}
+	message, err := json.Marshal(response{Type: "update", Values: values})
+	if err != nil {
+		return
+	}
+	select {
+	case sess.send <- message:
+	default:
+	}
+}
+
+// reply queues an answer to a request, waiting if the queue is full.
+func (sess *session) reply(resp response) {
+	message, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	select {
+	case sess.send <- message:
+	case <-sess.closed:
+	}
+}
+
+// write sends the queued messages until the session is closed.
+func (sess *session) write() {
+	for {
+		select {
+		case message := <-sess.send:
+			if err := sess.ws.WriteMessage(message); err != nil {
+				sess.close(closeGoingAway)
+				return
+			}
+		case <-sess.closed:
+			return
+		}
+	}
+}
+
+func (sess *session) close(code uint16) {
+	sess.closeOnce.Do(func() {
+		close(sess.closed)
+		sess.ws.Close(code)
+	})
+}
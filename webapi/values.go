@@ -0,0 +1,139 @@
+package webapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/xpudp"
+)
+
+// lookup registers a dataref in the cache and returns its types. It must be
+// called on the simulator thread.
+func (s *Server) lookup(name string) (dref.DataType, error) {
+	if err := s.cache.Register(name); err != nil {
+		return 0, err
+	}
+	return s.cache.Type(name)
+}
+
+// readValue returns the value of a dataref, or of an array element when the
+// name ends with an index, as a number, an array of numbers, or a string for
+// byte data. It must be called on the simulator thread.
+func (s *Server) readValue(name string) (any, error) {
+	base, index := xpudp.SplitIndex(name)
+	types, err := s.lookup(base)
+	if err != nil {
+		return nil, err
+	}
+	if index >= 0 {
+		switch {
+		case types&dref.TypeFloatArray != 0:
+			var v [1]float32
+			if n, err := s.cache.GetFloatArray(base, v[:], index); err != nil || n == 0 {
+				return nil, indexError(name, err)
+			}
+			return v[0], nil
+		case types&dref.TypeIntArray != 0:
+			var v [1]int32
+			if n, err := s.cache.GetIntArray(base, v[:], index); err != nil || n == 0 {
+				return nil, indexError(name, err)
+			}
+			return v[0], nil
+		}
+		return nil, fmt.Errorf("dataref '%s' is %s, not an array: %w", base, types, dref.ErrTypeMismatch)
+	}
+
+	switch {
+	case types&dref.TypeDouble != 0:
+		return s.cache.GetDouble(name)
+	case types&dref.TypeFloat != 0:
+		return s.cache.GetFloat(name)
+	case types&dref.TypeInt != 0:
+		return s.cache.GetInt(name)
+	case types&(dref.TypeFloatArray|dref.TypeIntArray) != 0:
+		n, err := s.cache.ArrayLen(name)
+		if err != nil {
+			return nil, err
+		}
+		if types&dref.TypeFloatArray != 0 {
+			values := make([]float32, n)
+			_, err = s.cache.GetFloatArray(name, values, 0)
+			return values, err
+		}
+		values := make([]int32, n)
+		_, err = s.cache.GetIntArray(name, values, 0)
+		return values, err
+	case types&dref.TypeData != 0:
+		return s.cache.GetString(name)
+	}
+	return nil, fmt.Errorf("dataref '%s' is %s: %w", name, types, dref.ErrTypeMismatch)
+}
+
+// writeValue sets a dataref from a JSON value: a number for scalars and
+// array elements, an array of numbers for arrays written from index 0, or a
+// string for byte data. It must be called on the simulator thread.
+func (s *Server) writeValue(name string, raw json.RawMessage) error {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return fmt.Errorf("value of '%s': %w", name, errBadRequest)
+	}
+	base, index := xpudp.SplitIndex(name)
+	types, err := s.lookup(base)
+	if err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		switch {
+		case index >= 0 && types&dref.TypeFloatArray != 0:
+			return s.cache.SetFloatArray(base, []float32{float32(v)}, index)
+		case index >= 0 && types&dref.TypeIntArray != 0:
+			return s.cache.SetIntArray(base, []int32{int32(v)}, index)
+		case index >= 0:
+		case types&dref.TypeDouble != 0:
+			return s.cache.SetDouble(name, v)
+		case types&dref.TypeFloat != 0:
+			return s.cache.SetFloat(name, float32(v))
+		case types&dref.TypeInt != 0:
+			return s.cache.SetInt(name, int(v))
+		}
+	case []any:
+		numbers := make([]float64, len(v))
+		for i, e := range v {
+			f, ok := e.(float64)
+			if !ok {
+				return fmt.Errorf("value of '%s' is not an array of numbers: %w", name, errBadRequest)
+			}
+			numbers[i] = f
+		}
+		start := max(index, 0)
+		switch {
+		case types&dref.TypeFloatArray != 0:
+			values := make([]float32, len(numbers))
+			for i, f := range numbers {
+				values[i] = float32(f)
+			}
+			return s.cache.SetFloatArray(base, values, start)
+		case types&dref.TypeIntArray != 0:
+			values := make([]int32, len(numbers))
+			for i, f := range numbers {
+				values[i] = int32(f)
+			}
+			return s.cache.SetIntArray(base, values, start)
+		}
+	case string:
+		if index < 0 && types&dref.TypeData != 0 {
+			return s.cache.SetString(name, v)
+		}
+	}
+	return fmt.Errorf("cannot write %s to dataref '%s' of type %s: %w", raw, name, types, dref.ErrTypeMismatch)
+}
+
+func indexError(name string, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("dataref '%s': index out of range: %w", name, errBadRequest)
+}
@@ -0,0 +1,396 @@
+// Package webapi serves datarefs and commands over HTTP and WebSocket from
+// inside a plugin, including the datarefs published by plugins. Requests are
+// handled on the HTTP server's goroutines, but every simulator access is
+// queued and run from a flight loop on the simulator thread.
+//
+// Endpoints:
+//
+//	GET  /api/datarefs?prefix=sim/cockpit2/   list datarefs (X-Plane 12)
+//	GET  /api/datarefs/{name}                 {"name": ..., "value": ...}
+//	PUT  /api/datarefs/{name}                 body {"value": ...}
+//	POST /api/commands/{name}                 optional body {"duration": seconds}
+//	GET  /api/ws                              WebSocket subscription stream
+//
+// A name may end with an array index, "[3]" percent-encoded as "%5B3%5D" in
+// URLs. Values are numbers, arrays of numbers, or strings for byte data.
+// Request bodies must be sent with "Content-Type: application/json".
+//
+// Since any web page open on the simulator's machine can reach the server,
+// requests from browsers are refused unless their Origin is the server itself
+// or is listed in Options.AllowedOrigins. This keeps cross-site pages from
+// writing datarefs, running commands or opening a WebSocket.
+//
+// WebSocket clients send JSON requests, where "id" is optional and echoed
+// back in the "result" or "error" answer:
+//
+//	{"type": "subscribe", "id": 1, "datarefs": ["sim/flightmodel/position/elevation"], "rate": 10}
+//	{"type": "unsubscribe", "datarefs": ["sim/flightmodel/position/elevation"]}
+//	{"type": "set", "name": "sim/cockpit/autopilot/altitude", "value": 5000}
+//	{"type": "command", "name": "sim/operation/pause_toggle"}
+//
+// and receive {"type": "update", "values": {"name": value, ...}} messages at
+// the subscribed rates.
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/plugin"
+	"github.com/akhenakh/xplane-go/processing"
+	"github.com/akhenakh/xplane-go/util"
+)
+
+const (
+	// callQueueSize is the number of simulator calls waiting for the next
+	// flight loop.
+	callQueueSize = 256
+	// callTimeout bounds the wait for the simulator thread, which does not
+	// run flight loops while loading.
+	callTimeout = 5 * time.Second
+)
+
+var (
+	ErrStopped    = errors.New("webapi: server stopped")
+	errBadRequest = errors.New("bad request")
+	errNotJSON    = errors.New("request body must be application/json")
+	errOrigin     = errors.New("cross-origin request refused")
+)
+
+// Options tune a Server. The zero value only accepts same-origin browser
+// requests.
+type Options struct {
+	// AllowedOrigins are the origins, such as "http://localhost:3000", of
+	// the web pages allowed to use the API. "*" allows every origin.
+	AllowedOrigins []string
+}
+
+// Server is an HTTP server whose simulator accesses run on the simulator
+// thread.
+type Server struct {
+	cache    *dref.DataRefCache
+	origins  []string
+	http     *http.Server
+	listener net.Listener
+	loop     *processing.FlightLoop
+	calls    chan func()
+	done     chan struct{}
+	mutex    sync.Mutex
+
+	// Only used on the simulator thread.
+	sessions map[*session]bool
+	commands map[string]util.CommandRef
+	held     map[util.CommandRef]int
+	clock    float64
+}
+
+var (
+	active      = make(map[*Server]bool)
+	activeMutex sync.Mutex
+)
+
+func init() {
+	plugin.OnDisable(func() {
+		activeMutex.Lock()
+		servers := make([]*Server, 0, len(active))
+		for s := range active {
+			servers = append(servers, s)
+		}
+		activeMutex.Unlock()
+		for _, s := range servers {
+			s.Stop()
+		}
+	})
+}
+
+// Start listens on a TCP address such as "127.0.0.1:8086" and serves
+// datarefs from cache, registering them on first use. It must be called on
+// the simulator thread, and is stopped automatically when the plugin is
+// disabled.
+func Start(address string, cache *dref.DataRefCache, options Options) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("webapi: %w", err)
+	}
+	s := &Server{
+		cache:    cache,
+		origins:  slices.Clone(options.AllowedOrigins),
+		listener: listener,
+		calls:    make(chan func(), callQueueSize),
+		done:     make(chan struct{}),
+		sessions: make(map[*session]bool),
+		commands: make(map[string]util.CommandRef),
+		held:     make(map[util.CommandRef]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/datarefs", s.listDataRefs)
+	mux.HandleFunc("GET /api/datarefs/{name...}", s.getDataRef)
+	mux.HandleFunc("PUT /api/datarefs/{name...}", s.setDataRef)
+	mux.HandleFunc("POST /api/commands/{name...}", s.runCommand)
+	mux.HandleFunc("GET /api/ws", s.serveWebSocket)
+	s.http = &http.Server{Handler: s.checkOrigin(mux), ReadHeaderTimeout: 10 * time.Second}
+
+	s.loop = processing.NewFlightLoop(processing.AfterFlightModel, s.tick)
+	s.loop.Schedule(-1, true)
+	go s.http.Serve(listener)
+
+	activeMutex.Lock()
+	active[s] = true
+	activeMutex.Unlock()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stop closes the server, ends the commands still held and closes the
+// WebSocket connections in the background, without waiting for slow peers. It
+// must be called on the simulator thread.
+func (s *Server) Stop() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.loop == nil {
+		return nil
+	}
+	activeMutex.Lock()
+	delete(active, s)
+	activeMutex.Unlock()
+
+	s.loop.Destroy()
+	s.loop = nil
+	close(s.done)
+	// A stalled peer holds its close for up to closeTimeout, which must not
+	// freeze the simulator.
+	for sess := range s.sessions {
+		go sess.close(closeGoingAway)
+	}
+	for ref := range s.held {
+		util.CommandEnd(ref)
+	}
+	clear(s.held)
+	return s.http.Close()
+}
+
+// tick runs the queued calls and sends the due WebSocket updates.
+func (s *Server) tick(elapsedSinceLastCall, _ float32, _ int) float32 {
+	s.clock += float64(elapsedSinceLastCall)
+drain:
+	for {
+		select {
+		case call := <-s.calls:
+			call()
+		default:
+			break drain
+		}
+	}
+	for sess := range s.sessions {
+		sess.update(s.clock)
+	}
+	return -1
+}
+
+// do runs fn on the simulator thread and returns its error.
+func (s *Server) do(ctx context.Context, fn func() error) error {
+	result := make(chan error, 1)
+	select {
+	case s.calls <- func() { result <- fn() }:
+	case <-s.done:
+		return ErrStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-s.done:
+		return ErrStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// command runs a command once, or begins it and ends it after duration
+// seconds. It must be called on the simulator thread.
+func (s *Server) command(name string, duration float64) error {
+	ref, ok := s.commands[name]
+	if !ok {
+		var err error
+		if ref, err = util.FindCommand(name); err != nil {
+			return err
+		}
+		s.commands[name] = ref
+	}
+	if duration <= 0 {
+		util.CommandOnce(ref)
+		return nil
+	}
+	util.CommandBegin(ref)
+	s.held[ref]++
+	time.AfterFunc(time.Duration(duration*float64(time.Second)), func() {
+		// After Stop the call is not run; Stop has ended the command.
+		s.do(context.Background(), func() error {
+			if s.held[ref]--; s.held[ref] == 0 {
+				delete(s.held, ref)
+			}
+			util.CommandEnd(ref)
+			return nil
+		})
+	})
+	return nil
+}
+
+// checkOrigin refuses the requests of web pages from other origins. Requests
+// without an Origin header do not come from a browser page and are accepted.
+func (s *Server) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && !slices.Contains(s.origins, "*") && !slices.Contains(s.origins, origin) {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeError(w, fmt.Errorf("%w: %s", errOrigin, origin))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON decodes a JSON request body into v. Browsers cannot send
+// application/json to another origin without a preflight request, which the
+// server does not answer.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return errNotJSON
+	}
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMessageSize)).Decode(v)
+}
+
+type dataRefInfo struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Writable bool   `json:"writable"`
+}
+
+func (s *Server) listDataRefs(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	list := []dataRefInfo{}
+	err := s.call(r, func() error {
+		for _, info := range dref.AllDataRefs() {
+			if strings.HasPrefix(info.Name, prefix) {
+				list = append(list, dataRefInfo{Name: info.Name, Type: info.Type.String(), Writable: info.Writable})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+type dataRefValue struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+func (s *Server) getDataRef(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var value any
+	err := s.call(r, func() (err error) {
+		value, err = s.readValue(name)
+		return err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dataRefValue{Name: name, Value: value})
+}
+
+func (s *Server) setDataRef(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var body struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := decodeJSON(w, r, &body); errors.Is(err, errNotJSON) {
+		writeError(w, err)
+		return
+	} else if err != nil || body.Value == nil {
+		writeError(w, fmt.Errorf("expected {\"value\": ...}: %w", errBadRequest))
+		return
+	}
+	if err := s.call(r, func() error { return s.writeValue(name, body.Value) }); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) runCommand(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var body struct {
+		Duration float64 `json:"duration"`
+	}
+	// Without a body, cross-site requests are refused by their Origin.
+	if r.ContentLength != 0 {
+		if err := decodeJSON(w, r, &body); errors.Is(err, errNotJSON) {
+			writeError(w, err)
+			return
+		} else if err != nil {
+			writeError(w, fmt.Errorf("expected {\"duration\": seconds}: %w", errBadRequest))
+			return
+		}
+	}
+	if err := s.call(r, func() error { return s.command(name, body.Duration) }); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// call runs fn on the simulator thread for an HTTP request.
+func (s *Server) call(r *http.Request, fn func() error) error {
+	ctx, cancel := context.WithTimeout(r.Context(), callTimeout)
+	defer cancel()
+	return s.do(ctx, fn)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, dref.ErrDataRefNotFound), errors.Is(err, util.ErrCommandNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, dref.ErrDataRefReadOnly), errors.Is(err, errOrigin):
+		status = http.StatusForbidden
+	case errors.Is(err, errNotJSON):
+		status = http.StatusUnsupportedMediaType
+	case errors.Is(err, dref.ErrTypeMismatch), errors.Is(err, errBadRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrStopped):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
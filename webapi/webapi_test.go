@@ -0,0 +1,270 @@
+package webapi
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhenakh/xplane-go/dref"
+	"github.com/akhenakh/xplane-go/internal/xplmfake"
+)
+
+func startServer(t *testing.T, options Options) *Server {
+	t.Helper()
+	s, err := Start("127.0.0.1:0", dref.NewDataRefCache(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s
+}
+
+// serve runs a request through the server's handler while running frames on
+// the test goroutine, which owns the simulator.
+func serve(s *Server, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		s.http.Handler.ServeHTTP(w, r)
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return w
+		case <-time.After(time.Millisecond):
+			xplmfake.Frame(0.01)
+		}
+	}
+}
+
+func newRequest(method, path, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+func TestREST(t *testing.T) {
+	xplmfake.AddDataRef("xplane-go/test/webapi/float", xplmfake.TypeFloat, true)
+	xplmfake.AddDataRef("xplane-go/test/webapi/double", xplmfake.TypeFloat|xplmfake.TypeDouble, true)
+	xplmfake.AddDataRef("xplane-go/test/webapi/int", xplmfake.TypeInt, false)
+	xplmfake.AddDataRef("xplane-go/test/webapi/floats", xplmfake.TypeFloatArray, true)
+	xplmfake.AddDataRef("xplane-go/test/webapi/ints", xplmfake.TypeIntArray, true)
+	xplmfake.AddDataRef("xplane-go/test/webapi/string", xplmfake.TypeData, true)
+	s := startServer(t, Options{})
+
+	const api = "/api/datarefs/xplane-go/test/webapi/"
+	for _, tc := range []struct {
+		method, path, body string
+		contentType        string
+		status             int
+		want               string
+	}{
+		{"PUT", api + "float", `{"value": 1.5}`, "", 204, ""},
+		{"GET", api + "float", "", "", 200, `{"name":"xplane-go/test/webapi/float","value":1.5}`},
+		{"PUT", api + "double", `{"value": 0.1}`, "", 204, ""},
+		{"GET", api + "double", "", "", 200, `{"name":"xplane-go/test/webapi/double","value":0.1}`},
+		{"GET", api + "int", "", "", 200, `{"name":"xplane-go/test/webapi/int","value":0}`},
+		{"PUT", api + "floats", `{"value": [1, 2.5, 3]}`, "", 204, ""},
+		{"PUT", api + "floats%5B3%5D", `{"value": 4}`, "", 204, ""},
+		{"GET", api + "floats%5B1%5D", "", "", 200, `{"name":"xplane-go/test/webapi/floats[1]","value":2.5}`},
+		{"GET", api + "floats%5B3%5D", "", "", 200, `{"name":"xplane-go/test/webapi/floats[3]","value":4}`},
+		{"PUT", api + "ints%5B1%5D", `{"value": [7, 8]}`, "", 204, ""},
+		{"GET", api + "ints%5B2%5D", "", "", 200, `{"name":"xplane-go/test/webapi/ints[2]","value":8}`},
+		{"PUT", api + "string", `{"value": "N172SP"}`, "", 204, ""},
+		{"GET", api + "string", "", "", 200, `{"name":"xplane-go/test/webapi/string","value":"N172SP"}`},
+		{"GET", "/api/datarefs?prefix=xplane-go/test/webapi/i", "", "", 200,
+			`[{"name":"xplane-go/test/webapi/int","type":"int","writable":false},{"name":"xplane-go/test/webapi/ints","type":"int[]","writable":true}]`},
+
+		{"GET", api + "missing", "", "", 404, ""},
+		{"PUT", api + "missing", `{"value": 1}`, "", 404, ""},
+		{"POST", "/api/commands/sim/none", "", "", 404, ""},
+		{"PUT", api + "int", `{"value": 1}`, "", 403, ""},
+		{"PUT", api + "float", `{"value": "high"}`, "", 400, ""},
+		{"PUT", api + "string", `{"value": [1]}`, "", 400, ""},
+		{"PUT", api + "float%5B2%5D", `{"value": 1}`, "", 400, ""},
+		{"GET", api + "floats%5B99%5D", "", "", 400, ""},
+		{"PUT", api + "float", `{"velue": 1}`, "", 400, ""},
+		{"PUT", api + "float", `{"value": 1`, "", 400, ""},
+		{"PUT", api + "float", `{"value": 1}`, "text/plain", 415, ""},
+		{"PUT", api + "float", `{"value": 1}`, "application/x-www-form-urlencoded", 415, ""},
+		{"POST", "/api/commands/sim/none", `{"duration": 1}`, "text/plain", 415, ""},
+		{"POST", "/api/commands/sim/none", `{"duration": 1}`, "application/json; charset=utf-8", 404, ""},
+	} {
+		r := newRequest(tc.method, tc.path, tc.body)
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		w := serve(s, r)
+		name := tc.method + " " + tc.path + " " + tc.body
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", name, w.Code, tc.status, w.Body)
+			continue
+		}
+		body := strings.TrimSpace(w.Body.String())
+		if tc.want != "" && body != tc.want {
+			t.Errorf("%s: got %s, want %s", name, body, tc.want)
+		}
+		if w.Code >= 400 {
+			var e struct{ Error string }
+			if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil || e.Error == "" {
+				t.Errorf("%s: error body %s", name, body)
+			}
+		}
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	xplmfake.AddDataRef("xplane-go/test/webapi/origin", xplmfake.TypeFloat, true)
+	const path = "/api/datarefs/xplane-go/test/webapi/origin"
+	for _, tc := range []struct {
+		allowed []string
+		origin  string
+		status  int
+	}{
+		{nil, "", 204},
+		{nil, "http://example.com", 204},
+		{nil, "http://evil.example", 403},
+		{nil, "null", 403},
+		{nil, "http://example.com:8086", 403},
+		{[]string{"http://localhost:3000"}, "http://localhost:3000", 204},
+		{[]string{"http://localhost:3000"}, "http://localhost:3001", 403},
+		{[]string{"*"}, "http://evil.example", 204},
+	} {
+		s := startServer(t, Options{AllowedOrigins: tc.allowed})
+		// httptest.NewRequest uses the host example.com.
+		r := newRequest("PUT", path, `{"value": 1}`)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if w := serve(s, r); w.Code != tc.status {
+			t.Errorf("origin %q allowing %q: status %d, want %d", tc.origin, tc.allowed, w.Code, tc.status)
+		}
+		s.Stop()
+	}
+}
+
+// dial opens a WebSocket to the server, with an optional Origin, and returns
+// the handshake response status.
+func dial(t *testing.T, s *Server, origin string) (*wsConn, int) {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	var key [16]byte
+	rand.Read(key[:])
+	r, _ := http.NewRequest("GET", "http://"+s.Addr().String()+"/api/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key[:]))
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	r.Write(conn)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsConn{conn: conn, reader: reader, writer: bufio.NewWriter(conn)}, resp.StatusCode
+}
+
+// send writes a masked client message.
+func send(t *testing.T, c *wsConn, message string) {
+	t.Helper()
+	if _, err := c.conn.Write(text(true, message)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receive reads a server frame while running frames.
+func receive(t *testing.T, c *wsConn) response {
+	t.Helper()
+	type result struct {
+		payload []byte
+		err     error
+	}
+	got := make(chan result, 1)
+	go func() {
+		var header [2]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			got <- result{nil, err}
+			return
+		}
+		payload := make([]byte, header[1]&0x7f)
+		_, err := io.ReadFull(c.reader, payload)
+		got <- result{payload, err}
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r := <-got:
+			if r.err != nil {
+				t.Fatal(r.err)
+			}
+			var resp response
+			if err := json.Unmarshal(r.payload, &resp); err != nil {
+				t.Fatalf("%s: %v", r.payload, err)
+			}
+			return resp
+		case <-time.After(time.Millisecond):
+			xplmfake.Frame(0.01)
+		case <-timeout:
+			t.Fatal("no message from the server")
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	xplmfake.AddDataRef("xplane-go/test/webapi/ws", xplmfake.TypeFloat, true)
+	s := startServer(t, Options{})
+
+	if _, status := dial(t, s, "http://evil.example"); status != http.StatusForbidden {
+		t.Errorf("cross-origin handshake: status %d, want 403", status)
+	}
+	c, status := dial(t, s, "http://"+s.Addr().String())
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake: status %d, want 101", status)
+	}
+
+	send(t, c, `{"type": "set", "id": 1, "name": "xplane-go/test/webapi/ws", "value": 3}`)
+	if resp := receive(t, c); resp.Type != "result" || string(resp.ID) != "1" {
+		t.Errorf("set: got %+v", resp)
+	}
+	send(t, c, `{"type": "subscribe", "id": 2, "datarefs": ["xplane-go/test/webapi/ws", "xplane-go/test/webapi/none"]}`)
+	// The first update may come before the answer.
+	answers := make(map[string]response)
+	for range 2 {
+		resp := receive(t, c)
+		answers[resp.Type] = resp
+	}
+	if resp := answers["error"]; string(resp.ID) != "2" || !strings.Contains(resp.Error, "none") {
+		t.Errorf("subscribe with a missing dataref: got %+v", answers)
+	}
+	if resp := answers["update"]; fmt.Sprint(resp.Values) != "map[xplane-go/test/webapi/ws:3]" {
+		t.Errorf("update: got %+v", answers)
+	}
+	send(t, c, `{"type": "jump"}`)
+	for {
+		if resp := receive(t, c); resp.Type != "update" {
+			if resp.Type != "error" || !strings.Contains(resp.Error, "jump") {
+				t.Errorf("unknown request: got %+v", resp)
+			}
+			break
+		}
+	}
+}
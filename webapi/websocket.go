@@ -0,0 +1,242 @@
+package webapi
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal RFC 6455 server: text messages, fragmentation, ping and close.
+// Extensions and subprotocols are not supported.
+
+const (
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxMessageSize = 64 << 10
+	writeTimeout   = 10 * time.Second
+	// closeTimeout bounds Close on a stalled peer.
+	closeTimeout = time.Second
+)
+
+// Opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Close status codes.
+const (
+	closeNormal          = 1000
+	closeGoingAway       = 1001
+	closeProtocolError   = 1002
+	closeMessageTooLarge = 1009
+)
+
+var (
+	errProtocol        = errors.New("websocket protocol error")
+	errMessageTooLarge = errors.New("websocket message too large")
+)
+
+type wsConn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	writeMutex sync.Mutex
+	writer     *bufio.Writer
+	closeOnce  sync.Once
+}
+
+// upgrade answers a WebSocket handshake and hijacks the connection. On error
+// an HTTP error has already been written.
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!headerHas(r.Header, "Connection", "upgrade") ||
+		!headerHas(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, errProtocol
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errProtocol
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: rw.Reader, writer: rw.Writer}, nil
+}
+
+// headerHas reports whether a comma-separated header contains token.
+func headerHas(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// while waiting. It returns io.EOF when the peer closes the connection.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, op, payload, err := c.readFrame(maxMessageSize - len(message))
+		if err != nil {
+			switch {
+			case errors.Is(err, errMessageTooLarge):
+				c.Close(closeMessageTooLarge)
+			case errors.Is(err, errProtocol):
+				c.Close(closeProtocolError)
+			}
+			return nil, err
+		}
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload, writeTimeout); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := uint16(closeNormal)
+			if len(payload) >= 2 {
+				code = binary.BigEndian.Uint16(payload)
+			}
+			c.Close(code)
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				c.Close(closeProtocolError)
+				return nil, errProtocol
+			}
+			started = true
+		case opContinuation:
+			if !started {
+				c.Close(closeProtocolError)
+				return nil, errProtocol
+			}
+		default:
+			c.Close(closeProtocolError)
+			return nil, errProtocol
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads one frame, whose payload may not exceed limit bytes unless
+// it is a control frame.
+func (c *wsConn) readFrame(limit int) (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0f
+	if header[0]&0x70 != 0 || header[1]&0x80 == 0 {
+		// Reserved bits without extension, or an unmasked client frame.
+		err = errProtocol
+		return
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	control := op&0x8 != 0
+	if control && (!fin || length > 125) {
+		err = errProtocol
+		return
+	}
+	if !control && length > uint64(limit) {
+		err = errMessageTooLarge
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// WriteMessage sends a text message. It is safe to call concurrently.
+func (c *wsConn) WriteMessage(message []byte) error {
+	return c.writeFrame(opText, message, writeTimeout)
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte, timeout time.Duration) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	header := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	c.writer.Write(header)
+	c.writer.Write(payload)
+	return c.writer.Flush()
+}
+
+// Close sends a close frame with code and closes the connection.
+func (c *wsConn) Close(code uint16) {
+	c.closeOnce.Do(func() {
+		// Also interrupts a write blocked on a slow peer.
+		c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+		c.writeFrame(opClose, binary.BigEndian.AppendUint16(nil, code), closeTimeout)
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,239 @@
+package webapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// clientFrame encodes a frame as a client sends it, masked unless told
+// otherwise.
+func clientFrame(fin bool, op byte, payload []byte, masked bool) []byte {
+	b := []byte{op, 0}
+	if fin {
+		b[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		b[1] = byte(n)
+	case n <= 0xffff:
+		b[1] = 126
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b[1] = 127
+		b = binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	if !masked {
+		return append(b, payload...)
+	}
+	b[1] |= 0x80
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	b = append(b, mask...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	return b
+}
+
+func text(fin bool, s string) []byte { return clientFrame(fin, opText, []byte(s), true) }
+
+type serverFrame struct {
+	op      byte
+	payload string
+}
+
+// readServerFrames decodes the unmasked frames sent by the server.
+func readServerFrames(t *testing.T, b []byte) []serverFrame {
+	t.Helper()
+	var frames []serverFrame
+	for len(b) > 0 {
+		if len(b) < 2 || b[1]&0x80 != 0 || b[1]&0x7f > 125 {
+			t.Fatalf("unexpected server frame % x", b)
+		}
+		n := int(b[1])
+		frames = append(frames, serverFrame{b[0] & 0x0f, string(b[2 : 2+n])})
+		b = b[2+n:]
+	}
+	return frames
+}
+
+// readMessages reads messages from input until an error, and returns them
+// with the error and the frames the connection sent back.
+func readMessages(t *testing.T, input []byte) ([]string, error, []serverFrame) {
+	t.Helper()
+	server, peer := net.Pipe()
+	var sent bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&sent, peer)
+		close(done)
+	}()
+	c := &wsConn{conn: server, reader: bufio.NewReader(bytes.NewReader(input)), writer: bufio.NewWriter(server)}
+
+	var messages []string
+	var err error
+	for {
+		var message []byte
+		if message, err = c.ReadMessage(); err != nil {
+			break
+		}
+		messages = append(messages, string(message))
+	}
+	server.Close()
+	<-done
+	return messages, err, readServerFrames(t, sent.Bytes())
+}
+
+func closeFrame(code uint16) serverFrame {
+	return serverFrame{opClose, string(binary.BigEndian.AppendUint16(nil, code))}
+}
+
+func TestReadMessage(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	huge := strings.Repeat("y", 70000)
+	for _, tc := range []struct {
+		name     string
+		input    [][]byte
+		messages []string
+		err      error
+		sent     []serverFrame
+	}{
+		{
+			name:     "text",
+			input:    [][]byte{text(true, `{"type":"subscribe"}`), text(true, "")},
+			messages: []string{`{"type":"subscribe"}`, ""},
+			err:      io.EOF,
+		},
+		{
+			name:     "binary",
+			input:    [][]byte{clientFrame(true, opBinary, []byte{0, 1, 2}, true)},
+			messages: []string{"\x00\x01\x02"},
+			err:      io.EOF,
+		},
+		{
+			name:     "16-bit lengths",
+			input:    [][]byte{text(true, long), text(false, huge[:60000]), clientFrame(true, opContinuation, []byte(huge[60000:65000]), true)},
+			messages: []string{long, huge[:65000]},
+			err:      io.EOF,
+		},
+		{
+			name: "fragmented with a ping in between",
+			input: [][]byte{
+				text(false, "hel"),
+				clientFrame(true, opPing, []byte("are you there"), true),
+				clientFrame(false, opContinuation, []byte("lo "), true),
+				clientFrame(true, opPong, nil, true),
+				clientFrame(true, opContinuation, []byte("world"), true),
+			},
+			messages: []string{"hello world"},
+			err:      io.EOF,
+			sent:     []serverFrame{{opPong, "are you there"}},
+		},
+		{
+			name:     "close",
+			input:    [][]byte{text(true, "bye"), clientFrame(true, opClose, binary.BigEndian.AppendUint16(nil, closeGoingAway), true), text(true, "ignored")},
+			messages: []string{"bye"},
+			err:      io.EOF,
+			sent:     []serverFrame{closeFrame(closeGoingAway)},
+		},
+		{
+			name:  "close without a code",
+			input: [][]byte{clientFrame(true, opClose, nil, true)},
+			err:   io.EOF,
+			sent:  []serverFrame{closeFrame(closeNormal)},
+		},
+		{
+			name:  "unmasked",
+			input: [][]byte{clientFrame(true, opText, []byte("hi"), false)},
+			err:   errProtocol,
+			sent:  []serverFrame{closeFrame(closeProtocolError)},
+		},
+		{
+			name:  "reserved bits",
+			input: [][]byte{append([]byte{0x80 | 0x40 | opText}, text(true, "hi")[1:]...)},
+			err:   errProtocol,
+			sent:  []serverFrame{closeFrame(closeProtocolError)},
+		},
+		{
+			name:  "unknown opcode",
+			input: [][]byte{clientFrame(true, 0x3, []byte("hi"), true)},
+			err:   errProtocol,
+			sent:  []serverFrame{closeFrame(closeProtocolError)},
+		},
+		{
+			name:  "continuation first",
+			input: [][]byte{clientFrame(true, opContinuation, []byte("hi"), true)},
+			err:   errProtocol,
+			sent:  []serverFrame{closeFrame(closeProtocolError)},
+		},
+		{
+			name:  "new message inside a fragmented one",
+			input: [][]byte{text(false, "a"), text(true, "b")},
+			err:   errProtocol,
+			sent:  []serverFrame{closeFrame(closeProtocolError)},
+		},
+		{
+			name:  "fragmented control frame",
+			input: [][]byte{clientFrame(false, opPing, nil, true)},
+			err:   errProtocol,
+			sent:  []serverFrame{closeFrame(closeProtocolError)},
+		},
+		{
+			name:  "long control frame",
+			input: [][]byte{clientFrame(true, opPing, []byte(long), true)},
+			err:   errProtocol,
+			sent:  []serverFrame{closeFrame(closeProtocolError)},
+		},
+		{
+			name:  "message too large",
+			input: [][]byte{text(true, huge)},
+			err:   errMessageTooLarge,
+			sent:  []serverFrame{closeFrame(closeMessageTooLarge)},
+		},
+		{
+			name:  "fragments too large",
+			input: [][]byte{text(false, huge[:40000]), clientFrame(true, opContinuation, []byte(huge[40000:]), true)},
+			err:   errMessageTooLarge,
+			sent:  []serverFrame{closeFrame(closeMessageTooLarge)},
+		},
+		{
+			name:  "truncated",
+			input: [][]byte{text(true, "hello")[:8]},
+			err:   io.ErrUnexpectedEOF,
+		},
+	} {
+		messages, err, sent := readMessages(t, slices.Concat(tc.input...))
+		if !slices.Equal(messages, tc.messages) {
+			t.Errorf("%s: messages = %.40q, want %.40q", tc.name, messages, tc.messages)
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.err)
+		}
+		if !slices.Equal(sent, tc.sent) {
+			t.Errorf("%s: sent %q, want %q", tc.name, sent, tc.sent)
+		}
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xffff, 0x10000} {
+		server, peer := net.Pipe()
+		c := &wsConn{conn: server, reader: bufio.NewReader(server), writer: bufio.NewWriter(server)}
+		payload := bytes.Repeat([]byte{'z'}, n)
+		go func() {
+			c.WriteMessage(payload)
+			server.Close()
+		}()
+		got, _ := io.ReadAll(peer)
+		want := clientFrame(true, opText, payload, false)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%d bytes: frame header % x, want % x", n, got[:min(len(got), 10)], want[:min(len(want), 10)])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package xpudp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// BeaconAddress is the multicast group X-Plane announces itself on.
+const BeaconAddress = "239.255.1.1:49707"
+
+// Beacon roles.
+const (
+	RoleMaster         = 1
+	RoleExternalVisual = 2
+	RoleIOS            = 3
+)
+
+// Beacon is a BECN packet announcing a running X-Plane instance.
+type Beacon struct {
+	MajorVersion uint8
+	MinorVersion uint8
+	HostID       int32  // 1 for X-Plane, 2 for Plane Maker.
+	Version      int32  // e.g. 120100 for 12.1.0.
+	Role         uint32 // RoleMaster, RoleExternalVisual or RoleIOS.
+	Port         uint16 // UDP port X-Plane listens on.
+	ComputerName string
+	RaknetPort   uint16 // Only sent by beacon version 1.2 and later.
+
+	// IP is the address the beacon was received from. It is not part of
+	// the packet.
+	IP net.IP
+}
+
+// Address returns the host:port to send UDP packets to.
+func (b *Beacon) Address() string {
+	return net.JoinHostPort(b.IP.String(), strconv.Itoa(int(b.Port)))
+}
+
+// beaconFixedSize is the size of the fields before the computer name.
+const beaconFixedSize = headerSize + 1 + 1 + 4 + 4 + 4 + 2
+
+// ParseBeacon decodes a BECN packet.
+func ParseBeacon(packet []byte) (*Beacon, error) {
+	if len(packet) < headerSize || string(packet[:4]) != "BECN" {
+		return nil, ErrUnknownPacket
+	}
+	if len(packet) < beaconFixedSize {
+		return nil, ErrShortPacket
+	}
+	p := packet[headerSize:]
+	b := &Beacon{
+		MajorVersion: p[0],
+		MinorVersion: p[1],
+		HostID:       int32(binary.LittleEndian.Uint32(p[2:])),
+		Version:      int32(binary.LittleEndian.Uint32(p[6:])),
+		Role:         binary.LittleEndian.Uint32(p[10:]),
+		Port:         binary.LittleEndian.Uint16(p[14:]),
+	}
+	rest := packet[beaconFixedSize:]
+	b.ComputerName = cString(rest)
+	if rest = rest[min(len(b.ComputerName)+1, len(rest)):]; len(rest) >= 2 {
+		b.RaknetPort = binary.LittleEndian.Uint16(rest)
+	}
+	return b, nil
+}
+
+// MarshalBinary encodes the beacon as a version 1.2 BECN packet.
+func (b *Beacon) MarshalBinary() ([]byte, error) {
+	buf := append([]byte("BECN\x00"), b.MajorVersion, b.MinorVersion)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(b.HostID))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(b.Version))
+	buf = binary.LittleEndian.AppendUint32(buf, b.Role)
+	buf = binary.LittleEndian.AppendUint16(buf, b.Port)
+	buf = append(buf, b.ComputerName...)
+	buf = append(buf, 0)
+	buf = binary.LittleEndian.AppendUint16(buf, b.RaknetPort)
+	return buf, nil
+}
+
+// Discover waits for the beacon of a master X-Plane instance on the local
+// network, until ctx is done.
+func Discover(ctx context.Context) (*Beacon, error) {
+	group, err := net.ResolveUDPAddr("udp4", BeaconAddress)
+	if err != nil {
+		return nil, fmt.Errorf("xpudp: %w", err)
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, fmt.Errorf("xpudp: listening for beacons: %w", err)
+	}
+	defer conn.Close()
+	return discover(ctx, conn)
+}
+
+func discover(ctx context.Context, conn net.PacketConn) (*Beacon, error) {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("xpudp: %w", err)
+		}
+		b, err := ParseBeacon(buf[:n])
+		if err != nil || b.Role != RoleMaster {
+			continue
+		}
+		if udp, ok := addr.(*net.UDPAddr); ok {
+			b.IP = udp.IP
+		}
+		return b, nil
+	}
+}
@@ -0,0 +1,442 @@
+package xpudp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the time without packets after which a Client considers
+// the simulator gone and sends its subscriptions again.
+const DefaultTimeout = 3 * time.Second
+
+var ErrClientClosed = errors.New("xpudp: client closed")
+
+// ClientOptions configures a Client.
+type ClientOptions struct {
+	// Timeout is the time without packets after which subscriptions are sent
+	// again, e.g. because the simulator was restarted. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+	// OnDATA, if set, is called with the DATA packets sent by the simulator's
+	// "Data Output" screen.
+	OnDATA func([]DataGroup)
+	// OnConnectionChange, if set, is called when the simulator stops or
+	// starts answering subscriptions.
+	OnConnectionChange func(connected bool)
+}
+
+// Client talks to a running simulator over its UDP protocol, without being a
+// plugin. Callbacks are called from the receiving goroutine and must not
+// block.
+type Client struct {
+	options ClientOptions
+	address string
+	conn    *net.UDPConn
+
+	mutex     sync.Mutex
+	addr      *net.UDPAddr
+	subs      map[int32]*Subscription
+	nextIndex int32
+	last      time.Time
+	connected bool
+	closed    bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Dial creates a client sending to a simulator at address, such as
+// "127.0.0.1:49000". The address is resolved again when the simulator stops
+// answering.
+func Dial(address string, options ClientOptions) (*Client, error) {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("xpudp: %w", err)
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, fmt.Errorf("xpudp: %w", err)
+	}
+	c := &Client{
+		options: options,
+		address: address,
+		conn:    conn,
+		addr:    addr,
+		subs:    make(map[int32]*Subscription),
+		last:    time.Now(),
+		done:    make(chan struct{}),
+	}
+	c.wg.Add(2)
+	go c.receive()
+	go c.watch()
+	return c, nil
+}
+
+// LocalAddr returns the address the client receives on.
+func (c *Client) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// Connected reports whether the simulator answered within the timeout.
+func (c *Client) Connected() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.connected
+}
+
+// Subscribe asks the simulator to send a dataref frequency times per second.
+// name may end with an array index, e.g. "sim/flightmodel/engine/ENGN_N1_[0]".
+// fn, if not nil, is called with every value received.
+func (c *Client) Subscribe(name string, frequency int, fn func(float32)) (*Subscription, error) {
+	if frequency <= 0 {
+		return nil, fmt.Errorf("xpudp: subscribing to '%s': frequency must be positive", name)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	c.nextIndex++
+	s := &Subscription{
+		client:    c,
+		name:      name,
+		index:     c.nextIndex,
+		frequency: int32(frequency),
+		fn:        fn,
+	}
+	if err := c.sendLocked(RREFRequest{Frequency: s.frequency, Index: s.index, Name: name}); err != nil {
+		return nil, fmt.Errorf("xpudp: subscribing to '%s': %w", name, err)
+	}
+	c.subs[s.index] = s
+	return s, nil
+}
+
+// SubscribeArray subscribes to the first count elements of an array dataref.
+func (c *Client) SubscribeArray(name string, count, frequency int) (*ArraySubscription, error) {
+	a := &ArraySubscription{elements: make([]*Subscription, 0, count)}
+	for i := range count {
+		s, err := c.Subscribe(fmt.Sprintf("%s[%d]", name, i), frequency, nil)
+		if err != nil {
+			a.Unsubscribe()
+			return nil, err
+		}
+		a.elements = append(a.elements, s)
+	}
+	return a, nil
+}
+
+// Get reads a dataref once, by subscribing until the first value arrives.
+func (c *Client) Get(ctx context.Context, name string) (float32, error) {
+	values := make(chan float32, 1)
+	s, err := c.Subscribe(name, 1, func(v float32) {
+		select {
+		case values <- v:
+		default:
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer s.Unsubscribe()
+	select {
+	case v := <-values:
+		return v, nil
+	case <-ctx.Done():
+		return 0, fmt.Errorf("xpudp: reading '%s': %w", name, ctx.Err())
+	case <-c.done:
+		return 0, ErrClientClosed
+	}
+}
+
+// Set writes a dataref, or an array element when name ends with an index.
+func (c *Client) Set(name string, value float32) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.sendLocked(DREF{Value: value, Name: name}); err != nil {
+		return fmt.Errorf("xpudp: setting '%s': %w", name, err)
+	}
+	return nil
+}
+
+// Command runs a command once.
+func (c *Client) Command(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.sendLocked(CMND{Name: name}); err != nil {
+		return fmt.Errorf("xpudp: running '%s': %w", name, err)
+	}
+	return nil
+}
+
+// Resubscribe resolves the address again and sends all subscriptions. It is
+// called automatically when the simulator stops answering.
+func (c *Client) Resubscribe() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.resubscribeLocked()
+}
+
+// Close cancels all subscriptions and closes the connection.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	for _, s := range c.subs {
+		c.sendLocked(RREFRequest{Index: s.index, Name: s.name})
+	}
+	clear(c.subs)
+	c.closed = true
+	close(c.done)
+	c.mutex.Unlock()
+
+	err := c.conn.Close()
+	c.wg.Wait()
+	return err
+}
+
+func (c *Client) resubscribeLocked() error {
+	if c.closed {
+		return ErrClientClosed
+	}
+	if addr, err := net.ResolveUDPAddr("udp", c.address); err == nil {
+		c.addr = addr
+	}
+	for _, s := range c.subs {
+		if err := c.sendLocked(RREFRequest{Frequency: s.frequency, Index: s.index, Name: s.name}); err != nil {
+			return fmt.Errorf("xpudp: subscribing to '%s': %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) sendLocked(msg interface{ MarshalBinary() ([]byte, error) }) error {
+	if c.closed {
+		return ErrClientClosed
+	}
+	buf, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteToUDP(buf, c.addr)
+	return err
+}
+
+// receive decodes packets until the connection is closed.
+func (c *Client) receive() {
+	defer c.wg.Done()
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-c.done:
+				return
+			default:
+				// E.g. an ICMP error from a previous write; keep listening.
+				continue
+			}
+		}
+		if n < headerSize {
+			continue
+		}
+		switch string(buf[:4]) {
+		case "RREF":
+			values, _ := ParseRREFResponse(buf[:n])
+			c.alive()
+			for _, v := range values {
+				c.update(v)
+			}
+		case "DATA":
+			groups, _ := ParseDATA(buf[:n])
+			c.alive()
+			if c.options.OnDATA != nil {
+				c.options.OnDATA(groups)
+			}
+		}
+	}
+}
+
+func (c *Client) alive() {
+	c.mutex.Lock()
+	c.last = time.Now()
+	changed := !c.connected
+	c.connected = true
+	c.mutex.Unlock()
+	if changed && c.options.OnConnectionChange != nil {
+		c.options.OnConnectionChange(true)
+	}
+}
+
+func (c *Client) update(v RREFValue) {
+	c.mutex.Lock()
+	s := c.subs[v.Index]
+	if s == nil {
+		// A late value for a cancelled subscription.
+		c.mutex.Unlock()
+		return
+	}
+	s.value = v.Value
+	s.updated = c.last
+	fn := s.fn
+	c.mutex.Unlock()
+	if fn != nil {
+		fn(v.Value)
+	}
+}
+
+// watch sends the subscriptions again when nothing was received for the
+// timeout. Without subscriptions the simulator sends nothing, so silence is
+// only meaningful when there are some.
+func (c *Client) watch() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.options.Timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		c.mutex.Lock()
+		if len(c.subs) == 0 || time.Since(c.last) < c.options.Timeout {
+			c.mutex.Unlock()
+			continue
+		}
+		changed := c.connected
+		c.connected = false
+		c.last = time.Now()
+		c.resubscribeLocked()
+		c.mutex.Unlock()
+		if changed && c.options.OnConnectionChange != nil {
+			c.options.OnConnectionChange(false)
+		}
+	}
+}
+
+// Subscription is an RREF subscription to one dataref or array element.
+type Subscription struct {
+	client    *Client
+	name      string
+	index     int32
+	frequency int32
+	fn        func(float32)
+
+	// Guarded by client.mutex.
+	value   float32
+	updated time.Time
+}
+
+// Name returns the subscribed dataref name.
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// Value returns the last value received, and false if none was received yet.
+func (s *Subscription) Value() (float32, bool) {
+	s.client.mutex.Lock()
+	defer s.client.mutex.Unlock()
+	return s.value, !s.updated.IsZero()
+}
+
+// Updated returns the time the last value was received.
+func (s *Subscription) Updated() time.Time {
+	s.client.mutex.Lock()
+	defer s.client.mutex.Unlock()
+	return s.updated
+}
+
+// Float returns the last value received.
+func (s *Subscription) Float() float32 {
+	v, _ := s.Value()
+	return v
+}
+
+// Int returns the last value received as an int dataref.
+func (s *Subscription) Int() int {
+	v, _ := s.Value()
+	return int(v)
+}
+
+// Bool returns whether the last value received is not zero.
+func (s *Subscription) Bool() bool {
+	v, _ := s.Value()
+	return v != 0
+}
+
+// Unsubscribe asks the simulator to stop sending the dataref.
+func (s *Subscription) Unsubscribe() error {
+	c := s.client
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.subs[s.index] != s {
+		return nil
+	}
+	delete(c.subs, s.index)
+	if err := c.sendLocked(RREFRequest{Index: s.index, Name: s.name}); err != nil {
+		return fmt.Errorf("xpudp: unsubscribing from '%s': %w", s.name, err)
+	}
+	return nil
+}
+
+// ArraySubscription subscribes to the elements of an array dataref.
+type ArraySubscription struct {
+	elements []*Subscription
+}
+
+// Len returns the number of elements subscribed.
+func (a *ArraySubscription) Len() int {
+	return len(a.elements)
+}
+
+// Element returns the subscription of element i.
+func (a *ArraySubscription) Element(i int) *Subscription {
+	return a.elements[i]
+}
+
+// Floats returns the last values received.
+func (a *ArraySubscription) Floats() []float32 {
+	values := make([]float32, len(a.elements))
+	for i, s := range a.elements {
+		values[i] = s.Float()
+	}
+	return values
+}
+
+// Ints returns the last values received as int elements.
+func (a *ArraySubscription) Ints() []int {
+	values := make([]int, len(a.elements))
+	for i, s := range a.elements {
+		values[i] = s.Int()
+	}
+	return values
+}
+
+// String decodes a byte array dataref, such as a tail number, up to the first
+// null byte.
+func (a *ArraySubscription) String() string {
+	b := make([]byte, len(a.elements))
+	for i, s := range a.elements {
+		b[i] = byte(s.Int())
+	}
+	return cString(b)
+}
+
+// Unsubscribe cancels the subscriptions of all elements.
+func (a *ArraySubscription) Unsubscribe() error {
+	var errs []error
+	for _, s := range a.elements {
+		if err := s.Unsubscribe(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,187 @@
+package xpudp_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akhenakh/xplane-go/xpudp"
+	"github.com/akhenakh/xplane-go/xpudp/xpudptest"
+)
+
+// eventually polls cond until it holds or a few seconds passed.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newClient(t *testing.T, options xpudp.ClientOptions) (*xpudptest.Server, *xpudp.Client) {
+	t.Helper()
+	server, err := xpudptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	client, err := xpudp.Dial(server.Address(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestSubscribe(t *testing.T) {
+	server, client := newClient(t, xpudp.ClientOptions{})
+	server.Set("sim/test/altitude", 1500)
+
+	var mutex sync.Mutex
+	var received []float32
+	sub, err := client.Subscribe("sim/test/altitude", 50, func(v float32) {
+		mutex.Lock()
+		received = append(received, v)
+		mutex.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the first value", func() bool { return sub.Float() == 1500 })
+	if !client.Connected() {
+		t.Error("not connected after receiving a value")
+	}
+
+	server.Set("sim/test/altitude", 1600)
+	eventually(t, "the updated value", func() bool { return sub.Int() == 1600 })
+	mutex.Lock()
+	if !slices.Contains(received, 1500) || !slices.Contains(received, 1600) {
+		t.Errorf("callback received %v, want 1500 and 1600", received)
+	}
+	mutex.Unlock()
+
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	server.Set("sim/test/altitude", 1700)
+	time.Sleep(5 * xpudptest.TickInterval)
+	if v := sub.Float(); v == 1700 {
+		t.Error("value updated after Unsubscribe")
+	}
+}
+
+func TestSubscribeArray(t *testing.T) {
+	server, client := newClient(t, xpudp.ClientOptions{})
+	server.Set("sim/test/n1", 10, 20, 30)
+
+	array, err := client.SubscribeArray("sim/test/n1", 3, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float32{10, 20, 30}
+	eventually(t, "the array", func() bool { return slices.Equal(array.Floats(), want) })
+	if got := array.Element(1).Name(); got != "sim/test/n1[1]" {
+		t.Errorf("Element(1).Name() = %q", got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	server, client := newClient(t, xpudp.ClientOptions{})
+	server.Set("sim/test/heading", 270)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	v, err := client.Get(ctx, "sim/test/heading")
+	if err != nil || v != 270 {
+		t.Errorf("Get = %v, %v, want 270", v, err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	server, client := newClient(t, xpudp.ClientOptions{})
+	server.Set("sim/test/flaps", 0)
+	server.Set("sim/test/n1", 0, 0)
+
+	if err := client.Set("sim/test/flaps", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set("sim/test/n1[1]", 95); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the DREF writes", func() bool {
+		flaps, _ := server.Get("sim/test/flaps")
+		n1, _ := server.Get("sim/test/n1")
+		return slices.Equal(flaps, []float32{0.5}) && slices.Equal(n1, []float32{0, 95})
+	})
+}
+
+func TestCommand(t *testing.T) {
+	server, client := newClient(t, xpudp.ClientOptions{})
+
+	for _, name := range []string{"sim/operation/pause_toggle", "sim/lights/landing_lights_on"} {
+		if err := client.Command(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"sim/operation/pause_toggle", "sim/lights/landing_lights_on"}
+	eventually(t, "the commands", func() bool { return slices.Equal(server.Commands(), want) })
+}
+
+func TestResubscribeAfterRestart(t *testing.T) {
+	changes := make(chan bool, 16)
+	server, client := newClient(t, xpudp.ClientOptions{
+		Timeout:            100 * time.Millisecond,
+		OnConnectionChange: func(connected bool) { changes <- connected },
+	})
+	server.Set("sim/test/speed", 120)
+
+	sub, err := client.Subscribe("sim/test/speed", 50, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the first value", func() bool { return sub.Float() == 120 })
+
+	// The restarted simulator forgot the subscription; only the client
+	// sending it again brings the new value.
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	server.Set("sim/test/speed", 140)
+	eventually(t, "the value after the restart", func() bool { return sub.Float() == 140 })
+
+	var got []bool
+	for len(changes) > 0 {
+		got = append(got, <-changes)
+	}
+	if want := []bool{true, false, true}; !slices.Equal(got, want) {
+		t.Errorf("connection changes = %v, want %v", got, want)
+	}
+}
+
+func TestDATA(t *testing.T) {
+	groups := make(chan []xpudp.DataGroup, 1)
+	server, client := newClient(t, xpudp.ClientOptions{
+		OnDATA: func(g []xpudp.DataGroup) { groups <- g },
+	})
+
+	want := []xpudp.DataGroup{
+		{Index: 3, Values: [8]float32{120, 118, xpudp.DataUnused, 121, 0, 138, 141, 0}},
+		{Index: 17, Values: [8]float32{2.5, 1, 270, 268}},
+	}
+	if err := server.SendDATA(client.LocalAddr(), want); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-groups:
+		if !slices.Equal(got, want) {
+			t.Errorf("OnDATA got %v, want %v", got, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for DATA")
+	}
+}
@@ -0,0 +1,49 @@
+package xpudp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// DataUnused is the value X-Plane sends in the unused slots of a DATA group.
+const DataUnused = -999
+
+// dataGroupSize is the size of one group: an index and eight floats.
+const dataGroupSize = 4 + 8*4
+
+// DataGroup is one row of the "Data Output" screen, as sent in DATA packets.
+// Index is the row number and Values its eight columns; unused columns hold
+// DataUnused.
+type DataGroup struct {
+	Index  int32
+	Values [8]float32
+}
+
+// ParseDATA decodes a DATA packet. A trailing incomplete group is ignored.
+func ParseDATA(packet []byte) ([]DataGroup, error) {
+	if len(packet) < headerSize || string(packet[:4]) != "DATA" {
+		return nil, ErrUnknownPacket
+	}
+	body := packet[headerSize:]
+	groups := make([]DataGroup, 0, len(body)/dataGroupSize)
+	for ; len(body) >= dataGroupSize; body = body[dataGroupSize:] {
+		g := DataGroup{Index: int32(binary.LittleEndian.Uint32(body))}
+		for i := range g.Values {
+			g.Values[i] = math.Float32frombits(binary.LittleEndian.Uint32(body[4+4*i:]))
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// AppendDATA encodes groups as a DATA packet.
+func AppendDATA(buf []byte, groups []DataGroup) []byte {
+	buf = append(buf, "DATA*"...)
+	for _, g := range groups {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(g.Index))
+		for _, v := range g.Values {
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+		}
+	}
+	return buf
+}
@@ -0,0 +1,176 @@
+// Package xpudp implements X-Plane's UDP protocol: RREF subscriptions, DREF
+// writes, CMND commands, DATA output and BECN beacons. It is pure Go. The
+// Client talks to a running simulator from an external program. The Server
+// reaches the simulator through the Sim interface, so it can run inside a
+// plugin (see the udpserver package) or against a fake in tests (see the
+// xpudptest package).
+package xpudp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultPort is the port X-Plane listens on.
+const DefaultPort = 49000
+
+// Sizes of the fixed-length packets.
+const (
+	rrefRequestSize = 413 // "RREF\0", frequency, index, 400-byte name.
+	drefSize        = 509 // "DREF\0", value, 500-byte name.
+	rrefNameSize    = 400
+	drefNameSize    = 500
+	headerSize      = 5
+)
+
+// MaxValuesPerPacket is the number of values sent in one RREF response.
+const MaxValuesPerPacket = 128
+
+var (
+	ErrShortPacket   = errors.New("xpudp: packet too short")
+	ErrUnknownPacket = errors.New("xpudp: unknown packet type")
+	ErrNameTooLong   = errors.New("xpudp: dataref name too long")
+)
+
+// RREFRequest subscribes to a dataref at Frequency times per second, or
+// cancels the subscription with Index when Frequency is 0. Name may end with
+// an array index, e.g. "sim/flightmodel/engine/ENGN_N1_[0]".
+type RREFRequest struct {
+	Frequency int32
+	Index     int32
+	Name      string
+}
+
+// RREFValue is one value of an RREF response.
+type RREFValue struct {
+	Index int32
+	Value float32
+}
+
+// DREF writes a value to a dataref, optionally an array element.
+type DREF struct {
+	Value float32
+	Name  string
+}
+
+// CMND runs a command once.
+type CMND struct {
+	Name string
+}
+
+// MarshalBinary encodes the request.
+func (r RREFRequest) MarshalBinary() ([]byte, error) {
+	if len(r.Name) >= rrefNameSize {
+		return nil, fmt.Errorf("%w: %q", ErrNameTooLong, r.Name)
+	}
+	buf := make([]byte, rrefRequestSize)
+	copy(buf, "RREF\x00")
+	binary.LittleEndian.PutUint32(buf[5:], uint32(r.Frequency))
+	binary.LittleEndian.PutUint32(buf[9:], uint32(r.Index))
+	copy(buf[13:], r.Name)
+	return buf, nil
+}
+
+// MarshalBinary encodes the write.
+func (d DREF) MarshalBinary() ([]byte, error) {
+	if len(d.Name) >= drefNameSize {
+		return nil, fmt.Errorf("%w: %q", ErrNameTooLong, d.Name)
+	}
+	buf := make([]byte, drefSize)
+	copy(buf, "DREF\x00")
+	binary.LittleEndian.PutUint32(buf[5:], math.Float32bits(d.Value))
+	copy(buf[9:], d.Name)
+	return buf, nil
+}
+
+// MarshalBinary encodes the command.
+func (c CMND) MarshalBinary() ([]byte, error) {
+	return append([]byte("CMND\x00"), c.Name...), nil
+}
+
+// AppendRREFResponse encodes an RREF response holding values.
+func AppendRREFResponse(buf []byte, values []RREFValue) []byte {
+	buf = append(buf, "RREF,"...)
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(v.Index))
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v.Value))
+	}
+	return buf
+}
+
+// ParseRREFResponse decodes the values of an RREF response.
+func ParseRREFResponse(packet []byte) ([]RREFValue, error) {
+	if len(packet) < headerSize || string(packet[:4]) != "RREF" {
+		return nil, ErrUnknownPacket
+	}
+	body := packet[headerSize:]
+	values := make([]RREFValue, 0, len(body)/8)
+	for ; len(body) >= 8; body = body[8:] {
+		values = append(values, RREFValue{
+			Index: int32(binary.LittleEndian.Uint32(body)),
+			Value: math.Float32frombits(binary.LittleEndian.Uint32(body[4:])),
+		})
+	}
+	return values, nil
+}
+
+// ParsePacket decodes a packet sent to the simulator. It returns an
+// RREFRequest, a DREF or a CMND.
+func ParsePacket(packet []byte) (any, error) {
+	if len(packet) < headerSize {
+		return nil, ErrShortPacket
+	}
+	switch string(packet[:4]) {
+	case "RREF":
+		if len(packet) < 13 {
+			return nil, ErrShortPacket
+		}
+		return RREFRequest{
+			Frequency: int32(binary.LittleEndian.Uint32(packet[5:])),
+			Index:     int32(binary.LittleEndian.Uint32(packet[9:])),
+			Name:      cString(packet[13:]),
+		}, nil
+	case "DREF":
+		if len(packet) < 9 {
+			return nil, ErrShortPacket
+		}
+		return DREF{
+			Value: math.Float32frombits(binary.LittleEndian.Uint32(packet[5:])),
+			Name:  cString(packet[9:]),
+		}, nil
+	case "CMND":
+		return CMND{Name: strings.TrimSpace(cString(packet[headerSize:]))}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownPacket, packet[:4])
+}
+
+// SplitIndex splits a name such as "sim/x/array[3]" into the dataref name and
+// array index. The index is -1 when the name has none.
+func SplitIndex(name string) (string, int) {
+	base, rest, ok := strings.Cut(name, "[")
+	if !ok {
+		return name, -1
+	}
+	index, ok := strings.CutSuffix(rest, "]")
+	if !ok {
+		return name, -1
+	}
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 {
+		return name, -1
+	}
+	return base, n
+}
+
+// cString returns the bytes of b up to the first null byte.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,260 @@
+package xpudp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Sim is the simulator side of a Server. Its methods are only called from
+// Tick, so a plugin can run them on the simulator thread.
+type Sim interface {
+	// Read returns the value of a dataref, or of one of its elements when
+	// index >= 0.
+	Read(name string, index int) (float32, error)
+	// Write sets a dataref, or one of its elements when index >= 0.
+	Write(name string, index int, value float32) error
+	// Command runs a command once.
+	Command(name string) error
+}
+
+// inboxSize is the number of packets buffered between two ticks.
+const inboxSize = 1024
+
+// Limits on the RREF subscriptions a Server keeps, since every subscription
+// is read on each Tick. Further subscriptions are refused and reported
+// through OnError.
+const (
+	MaxClients       = 64
+	MaxSubscriptions = 1024 // Per client.
+)
+
+var (
+	ErrTooManyClients       = errors.New("xpudp: too many clients")
+	ErrTooManySubscriptions = errors.New("xpudp: too many subscriptions")
+)
+
+type packet struct {
+	addr net.Addr
+	msg  any
+}
+
+type subscription struct {
+	name   string // Dataref name without the index suffix.
+	index  int    // Array element, or -1.
+	period float64
+	next   float64
+}
+
+type client struct {
+	addr net.Addr
+	subs map[int32]*subscription
+}
+
+// Server answers the X-Plane UDP protocol. Serve receives packets on its own
+// goroutine; they are applied and subscriptions are answered by Tick, which
+// the owner calls regularly, e.g. every frame from a flight loop.
+type Server struct {
+	// OnError, if set, is called with malformed packets from the Serve
+	// goroutine, and from Tick with errors returned by the Sim and refused
+	// subscriptions. It must be set before Serve is started.
+	OnError func(error)
+
+	conn    net.PacketConn
+	sim     Sim
+	inbox   chan packet
+	dropped atomic.Int64
+	closed  atomic.Bool
+
+	// Only used by Tick.
+	clients map[string]*client
+	clock   float64
+	buf     []byte
+	values  []RREFValue
+
+	closeOnce sync.Once
+}
+
+// NewServer creates a server answering on conn.
+func NewServer(conn net.PacketConn, sim Sim) *Server {
+	return &Server{
+		conn:    conn,
+		sim:     sim,
+		inbox:   make(chan packet, inboxSize),
+		clients: make(map[string]*client),
+	}
+}
+
+// Listen creates a server on a UDP address such as ":49000".
+func Listen(address string, sim Sim) (*Server, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("xpudp: %w", err)
+	}
+	return NewServer(conn, sim), nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Serve receives packets until Close is called. Packets are queued for the
+// next Tick; when the queue is full they are dropped and counted.
+func (s *Server) Serve() error {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if s.closed.Load() {
+				return nil
+			}
+			return fmt.Errorf("xpudp: %w", err)
+		}
+		msg, err := ParsePacket(buf[:n])
+		if err != nil {
+			s.report(fmt.Errorf("from %s: %w", addr, err))
+			continue
+		}
+		select {
+		case s.inbox <- packet{addr: addr, msg: msg}:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of packets dropped because Tick was not called
+// often enough.
+func (s *Server) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close stops Serve and closes the connection.
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// Tick applies the packets received since the last call, then sends the
+// subscriptions that are due. elapsed is the time in seconds since the last
+// call.
+func (s *Server) Tick(elapsed float64) {
+	s.clock += elapsed
+drain:
+	for {
+		select {
+		case p := <-s.inbox:
+			s.handle(p)
+		default:
+			break drain
+		}
+	}
+	if !s.closed.Load() {
+		for _, c := range s.clients {
+			s.send(c)
+		}
+	}
+}
+
+// Subscriptions returns the number of active RREF subscriptions. It must be
+// called from the goroutine calling Tick.
+func (s *Server) Subscriptions() int {
+	n := 0
+	for _, c := range s.clients {
+		n += len(c.subs)
+	}
+	return n
+}
+
+func (s *Server) handle(p packet) {
+	switch msg := p.msg.(type) {
+	case RREFRequest:
+		s.subscribe(p.addr, msg)
+	case DREF:
+		name, index := SplitIndex(msg.Name)
+		if err := s.sim.Write(name, index, msg.Value); err != nil {
+			s.report(fmt.Errorf("DREF %s from %s: %w", msg.Name, p.addr, err))
+		}
+	case CMND:
+		if err := s.sim.Command(msg.Name); err != nil {
+			s.report(fmt.Errorf("CMND %s from %s: %w", msg.Name, p.addr, err))
+		}
+	}
+}
+
+func (s *Server) subscribe(addr net.Addr, req RREFRequest) {
+	key := addr.String()
+	c := s.clients[key]
+	if req.Frequency <= 0 {
+		if c != nil {
+			delete(c.subs, req.Index)
+			if len(c.subs) == 0 {
+				delete(s.clients, key)
+			}
+		}
+		return
+	}
+	if c == nil {
+		if len(s.clients) >= MaxClients {
+			s.report(fmt.Errorf("RREF %s from %s: %w", req.Name, addr, ErrTooManyClients))
+			return
+		}
+		c = &client{addr: addr, subs: make(map[int32]*subscription)}
+		s.clients[key] = c
+	}
+	if _, exists := c.subs[req.Index]; !exists && len(c.subs) >= MaxSubscriptions {
+		s.report(fmt.Errorf("RREF %s from %s: %w", req.Name, addr, ErrTooManySubscriptions))
+		return
+	}
+	name, index := SplitIndex(req.Name)
+	// Answer right away, then at the requested rate.
+	c.subs[req.Index] = &subscription{
+		name:   name,
+		index:  index,
+		period: 1 / float64(req.Frequency),
+		next:   s.clock,
+	}
+}
+
+func (s *Server) send(c *client) {
+	s.values = s.values[:0]
+	for id, sub := range c.subs {
+		if sub.next > s.clock {
+			continue
+		}
+		sub.next += sub.period
+		if sub.next <= s.clock {
+			// Do not try to catch up after a long frame.
+			sub.next = s.clock + sub.period
+		}
+		// Like X-Plane, unknown datarefs are reported as 0.
+		v, err := s.sim.Read(sub.name, sub.index)
+		if err != nil {
+			v = 0
+		}
+		s.values = append(s.values, RREFValue{Index: id, Value: v})
+	}
+
+	for values := s.values; len(values) > 0; {
+		n := min(len(values), MaxValuesPerPacket)
+		s.buf = AppendRREFResponse(s.buf[:0], values[:n])
+		if _, err := s.conn.WriteTo(s.buf, c.addr); err != nil {
+			s.report(fmt.Errorf("RREF to %s: %w", c.addr, err))
+			return
+		}
+		values = values[n:]
+	}
+}
+
+func (s *Server) report(err error) {
+	if s.OnError != nil && !errors.Is(err, net.ErrClosed) {
+		s.OnError(err)
+	}
+}
@@ -0,0 +1,63 @@
+package xpudp
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+type nopSim struct{}
+
+func (nopSim) Read(name string, index int) (float32, error)      { return 0, nil }
+func (nopSim) Write(name string, index int, value float32) error { return nil }
+func (nopSim) Command(name string) error                         { return nil }
+
+func TestSubscriptionLimits(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(conn, nopSim{})
+	defer s.Close()
+	var errs []error
+	s.OnError = func(err error) { errs = append(errs, err) }
+
+	subscribe := func(port int, index int32, frequency int32) {
+		addr := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: port}
+		s.handle(packet{addr: addr, msg: RREFRequest{Frequency: frequency, Index: index, Name: "sim/test/value"}})
+	}
+	for i := range MaxSubscriptions {
+		subscribe(1, int32(i), 10)
+	}
+	// Subscribing an index again replaces it.
+	subscribe(1, 0, 20)
+	if len(errs) != 0 {
+		t.Fatalf("errors within the limit: %v", errs)
+	}
+	subscribe(1, MaxSubscriptions, 10)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrTooManySubscriptions) {
+		t.Errorf("errors = %v, want ErrTooManySubscriptions", errs)
+	}
+	if n := s.Subscriptions(); n != MaxSubscriptions {
+		t.Errorf("%d subscriptions, want %d", n, MaxSubscriptions)
+	}
+
+	errs = nil
+	for port := 2; port <= MaxClients; port++ {
+		subscribe(port, 0, 10)
+	}
+	subscribe(MaxClients+1, 0, 10)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrTooManyClients) {
+		t.Errorf("errors = %v, want ErrTooManyClients", errs)
+	}
+	// Unsubscribing makes room for another client.
+	subscribe(2, 0, 0)
+	errs = nil
+	subscribe(MaxClients+1, 0, 10)
+	if len(errs) != 0 {
+		t.Errorf("errors after a client left: %v", errs)
+	}
+	if n := s.Subscriptions(); n != MaxSubscriptions+MaxClients-1 {
+		t.Errorf("%d subscriptions, want %d", n, MaxSubscriptions+MaxClients-1)
+	}
+}
@@ -0,0 +1,172 @@
+// Package xpudptest provides a fake simulator speaking X-Plane's UDP
+// protocol, for testing clients without a running X-Plane.
+package xpudptest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/akhenakh/xplane-go/xpudp"
+)
+
+// TickInterval is how often the fake simulator answers subscriptions.
+const TickInterval = 10 * time.Millisecond
+
+var ErrNotFound = errors.New("xpudptest: dataref not found")
+
+// Server is a fake simulator listening on a local UDP port. Datarefs exist
+// once set with Set, and are written by DREF packets like in X-Plane.
+type Server struct {
+	mutex    sync.Mutex
+	udp      *xpudp.Server
+	address  string
+	values   map[string][]float32
+	commands []string
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewServer starts a fake simulator on a random local port.
+func NewServer() (*Server, error) {
+	s := &Server{values: make(map[string][]float32)}
+	if err := s.start("127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	s.address = s.udp.Addr().String()
+	return s, nil
+}
+
+// Address returns the host:port clients should send to.
+func (s *Server) Address() string {
+	return s.address
+}
+
+// Set creates or sets a dataref. Several values make an array dataref.
+func (s *Server) Set(name string, values ...float32) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[name] = slices.Clone(values)
+}
+
+// Get returns the values of a dataref, and false if it does not exist.
+func (s *Server) Get(name string) ([]float32, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values, ok := s.values[name]
+	return slices.Clone(values), ok
+}
+
+// Commands returns the commands received so far, in order.
+func (s *Server) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Clone(s.commands)
+}
+
+// SendDATA sends a DATA packet to addr, such as a client's LocalAddr.
+func (s *Server) SendDATA(addr net.Addr, groups []xpudp.DataGroup) error {
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(xpudp.AppendDATA(nil, groups))
+	return err
+}
+
+// Restart simulates a restart of the simulator: all subscriptions are lost,
+// datarefs are kept and the server listens again on the same address.
+func (s *Server) Restart() error {
+	s.shutdown()
+	return s.start(s.address)
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.shutdown()
+}
+
+func (s *Server) start(address string) error {
+	udp, err := xpudp.Listen(address, (*sim)(s))
+	if err != nil {
+		return err
+	}
+	s.udp = udp
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go udp.Serve()
+	go s.run(udp, s.stop, s.done)
+	return nil
+}
+
+func (s *Server) shutdown() error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+	return s.udp.Close()
+}
+
+func (s *Server) run(udp *xpudp.Server, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(TickInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			udp.Tick(now.Sub(last).Seconds())
+			last = now
+		}
+	}
+}
+
+// sim implements xpudp.Sim on the server's datarefs.
+type sim Server
+
+func (s *sim) Read(name string, index int) (float32, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values, ok := s.values[name]
+	switch {
+	case !ok:
+		return 0, fmt.Errorf("dataref '%s': %w", name, ErrNotFound)
+	case index < 0:
+		index = 0
+	case index >= len(values):
+		return 0, fmt.Errorf("dataref '%s' has no element %d: %w", name, index, ErrNotFound)
+	}
+	return values[index], nil
+}
+
+func (s *sim) Write(name string, index int, value float32) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values, ok := s.values[name]
+	if !ok {
+		return fmt.Errorf("dataref '%s': %w", name, ErrNotFound)
+	}
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(values) {
+		return fmt.Errorf("dataref '%s' has no element %d: %w", name, index, ErrNotFound)
+	}
+	values[index] = value
+	return nil
+}
+
+func (s *sim) Command(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commands = append(s.commands, name)
+	return nil
+}